@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/messaging"
+	"maimang/backend/internal/notify"
+	"maimang/backend/internal/oidc"
+)
+
+// startJobs 启动后台定时任务，ctx 取消时退出
+func startJobs(ctx context.Context, db *gorm.DB, m mailer.Mailer, logger *logrus.Logger) {
+	go runEvery(ctx, time.Hour, func() {
+		prune(logger, "expired refresh tokens", func() (int64, error) { return auth.PruneRefreshTokens(db) })
+		prune(logger, "ended sessions", func() (int64, error) { return auth.PruneSessions(db) })
+		prune(logger, "expired one-time tokens", func() (int64, error) { return auth.PruneOneTimeTokens(db) })
+		prune(logger, "stale login throttles", func() (int64, error) { return auth.PruneLoginThrottles(db) })
+		prune(logger, "expired oauth states", func() (int64, error) { return oidc.PruneStates(db) })
+		prune(logger, "unsent message attachments", func() (int64, error) { return messaging.PruneAttachments(db) })
+	})
+
+	// 发送通知邮件：即时邮件最多延迟一个周期，摘要按用户设置的频率发送
+	go runEvery(ctx, viper.GetDuration("NOTIFY_EMAIL_INTERVAL"), func() {
+		n, err := notify.SendDigests(db, m, viper.GetString("APP_BASE_URL"))
+		if err != nil {
+			logger.Errorf("send notification emails: %v", err)
+			return
+		}
+		if n > 0 {
+			logger.Infof("sent %d notification emails", n)
+		}
+	})
+
+	// 定期重新读取密钥目录，感知 `server keys rotate` 生成的新密钥
+	go runEvery(ctx, time.Minute, func() {
+		if ks := auth.CurrentKeySet(); ks != nil {
+			if err := ks.Reload(); err != nil {
+				logger.Errorf("reload signing keys: %v", err)
+			}
+		}
+	})
+}
+
+// prune 执行一次清理并记录结果
+func prune(logger *logrus.Logger, what string, fn func() (int64, error)) {
+	n, err := fn()
+	if err != nil {
+		logger.Errorf("prune %s: %v", what, err)
+		return
+	}
+	if n > 0 {
+		logger.Infof("pruned %d %s", n, what)
+	}
+}
+
+// runEvery 立即执行一次 fn，之后按 interval 周期执行
+func runEvery(ctx context.Context, interval time.Duration, fn func()) {
+	fn()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"maimang/backend/internal/auth"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage JWT signing keys",
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Generate a new signing key and retire old ones",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadConfig()
+		alg, _ := cmd.Flags().GetString("alg")
+		keep, _ := cmd.Flags().GetInt("keep")
+		if keep < 2 {
+			// 至少保留上一把密钥，已签发的令牌在过期前仍可校验
+			return fmt.Errorf("--keep must be at least 2")
+		}
+
+		dir := viper.GetString("JWT_KEY_DIR")
+		kid, err := auth.GenerateKeyFile(dir, alg)
+		if err != nil {
+			return fmt.Errorf("generate key: %w", err)
+		}
+		fmt.Printf("generated key %s in %s, used for signing after %s\n", kid, dir, auth.JWKSMaxAge)
+
+		removed, err := auth.PruneKeyFiles(dir, keep)
+		if err != nil {
+			return fmt.Errorf("prune keys: %w", err)
+		}
+		for _, id := range removed {
+			fmt.Printf("removed key %s\n", id)
+		}
+		return nil
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List signing keys",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadConfig()
+		ks, err := auth.LoadKeySet(viper.GetString("JWT_KEY_DIR"))
+		if err != nil {
+			return err
+		}
+		active, err := ks.Active()
+		if err != nil {
+			return err
+		}
+		for _, jwk := range ks.JWKS()["keys"].([]map[string]string) {
+			mark := ""
+			if jwk["kid"] == active.ID {
+				mark = " (active)"
+			} else if jwk["kid"] > active.ID {
+				mark = " (published, not yet signing)"
+			}
+			fmt.Printf("%s %s%s\n", jwk["kid"], jwk["alg"], mark)
+		}
+		return nil
+	},
+}
+
+func init() {
+	keysRotateCmd.Flags().String("alg", auth.AlgEdDSA, "signing algorithm: EdDSA or RS256")
+	keysRotateCmd.Flags().Int("keep", 3, "number of most recent keys to keep for verification")
+	keysCmd.AddCommand(keysRotateCmd)
+	keysCmd.AddCommand(keysListCmd)
+}
+
+// loadSigningKeys 加载密钥目录；目录为空时生成第一把密钥，方便本地开发
+func loadSigningKeys(logger *logrus.Logger) error {
+	auth.Issuer = viper.GetString("JWT_ISSUER")
+	dir := viper.GetString("JWT_KEY_DIR")
+	ks, err := auth.LoadKeySet(dir)
+	if err != nil {
+		return fmt.Errorf("load signing keys: %w", err)
+	}
+	if ks.Len() == 0 {
+		kid, err := auth.GenerateKeyFile(dir, auth.AlgEdDSA)
+		if err != nil {
+			return fmt.Errorf("generate signing key: %w", err)
+		}
+		logger.Warnf("no signing keys in %s, generated %s", dir, kid)
+		if err := ks.Reload(); err != nil {
+			return fmt.Errorf("load signing keys: %w", err)
+		}
+	}
+	auth.SetKeySet(ks)
+	return nil
+}
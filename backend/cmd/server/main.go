@@ -18,6 +18,11 @@ import (
 	"gorm.io/gorm"
 
 	"maimang/backend/internal/api"
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/oidc"
+	"maimang/backend/internal/rbac"
+	"maimang/backend/internal/realtime"
 	"maimang/backend/internal/repo"
 )
 
@@ -100,11 +105,28 @@ var serveCmd = &cobra.Command{
 		// auto migrate
 		if err := db.AutoMigrate(
 			&repo.User{},
+			&repo.Session{},
+			&repo.RefreshToken{},
+			&repo.UserToken{},
+			&repo.RecoveryCode{},
+			&repo.LoginThrottle{},
+			&repo.LockoutEvent{},
+			&repo.APIToken{},
+			&repo.InviteCode{},
+			&repo.OAuthState{},
+			&repo.UserIdentity{},
+			&repo.Permission{},
+			&repo.RolePermission{},
 			&repo.Article{},
 			&repo.Event{},
 			&repo.Album{},
 			&repo.Work{},
+			&repo.WorkLike{},
 			&repo.Comment{},
+			&repo.CommentLike{},
+			&repo.CommentRevision{},
+			&repo.CommentReviewLog{},
+			&repo.Mention{},
 			&repo.Activity{},
 			&repo.ActivityParticipant{},
 			&repo.Carousel{},
@@ -112,6 +134,13 @@ var serveCmd = &cobra.Command{
 			&repo.SystemSetting{},
 			&repo.Material{},
 			&repo.Message{},
+			&repo.MessageAttachment{},
+			&repo.ModerationCase{},
+			&repo.Follow{},
+			&repo.UserBlock{},
+			&repo.Notification{},
+			&repo.NotificationSetting{},
+			&repo.NotificationPreference{},
 		); err != nil {
 			return fmt.Errorf("migrate: %w", err)
 		}
@@ -121,8 +150,52 @@ var serveCmd = &cobra.Command{
 			log.Printf("Warning: failed to create unique index: %v", err)
 		}
 
+		// 写入新增权限点的默认授权
+		if err := rbac.Seed(db); err != nil {
+			return fmt.Errorf("seed permissions: %w", err)
+		}
+
+		// signing keys
+		if err := loadSigningKeys(logger); err != nil {
+			return err
+		}
+
 		// register routes
-		api.RegisterRoutes(app, db)
+		auth.SetStateCacheTTL(viper.GetDuration("AUTH_STATE_CACHE_TTL"))
+		auth.SetThrottlePolicy(auth.ThrottlePolicy{
+			EmailMaxFailures: viper.GetInt("LOGIN_EMAIL_MAX_FAILURES"),
+			IPMaxFailures:    viper.GetInt("LOGIN_IP_MAX_FAILURES"),
+			Lockout:          viper.GetDuration("LOGIN_LOCKOUT"),
+			Window:           viper.GetDuration("LOGIN_FAILURE_WINDOW"),
+			BackoffFree:      2,
+			BackoffBase:      time.Second,
+			BackoffMax:       time.Minute,
+		})
+		auth.SetPasswordPolicy(auth.PasswordPolicy{
+			MinLength:    viper.GetInt("PASSWORD_MIN_LENGTH"),
+			RejectCommon: viper.GetBool("PASSWORD_REJECT_COMMON"),
+		})
+
+		// 第三方登录（OIDC）
+		var oidcProviders map[string]oidc.ProviderConfig
+		if err := viper.UnmarshalKey("OIDC_PROVIDERS", &oidcProviders); err != nil {
+			return fmt.Errorf("oidc providers: %w", err)
+		}
+		if err := oidc.Configure(oidcProviders, viper.GetString("API_BASE_URL")); err != nil {
+			return err
+		}
+
+		m := newMailer()
+		hub := realtime.NewHub()
+		api.RegisterRoutes(app, db, m, hub)
+
+		// background jobs
+		jobsCtx, stopJobs := context.WithCancel(context.Background())
+		defer stopJobs()
+		startJobs(jobsCtx, db, m, logger)
+
+		// 实时推送：LISTEN 事件频道，分发给本实例的长连接
+		go hub.Listen(jobsCtx, dsn)
 
 		srvErr := make(chan error, 1)
 		go func() { srvErr <- app.Listen(viper.GetString("API_ADDR")) }()
@@ -136,6 +209,7 @@ var serveCmd = &cobra.Command{
 		case <-stop:
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
+			hub.Close()
 			_ = app.ShutdownWithContext(ctx)
 			_ = sqlDB.Close()
 			logger.Info("server stopped")
@@ -152,16 +226,50 @@ func loadConfig() {
 	_ = viper.ReadInConfig() // optional
 
 	viper.SetDefault("API_ADDR", ":8080")
-	viper.SetDefault("JWT_SECRET", "dev-secret-change-me")
+	viper.SetDefault("JWT_KEY_DIR", "./keys")
+	viper.SetDefault("JWT_ISSUER", "maimang")
 	viper.SetDefault("ACCESS_TOKEN_TTL", "2h")
 	viper.SetDefault("REFRESH_TOKEN_TTL", "168h")
+	viper.SetDefault("AUTH_STATE_CACHE_TTL", "30s")
+	viper.SetDefault("APP_BASE_URL", "http://localhost:3000")
+	viper.SetDefault("API_BASE_URL", "http://localhost:8080") // 后端对外地址，用于第三方登录回调
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("EMAIL_VERIFY_TTL", "72h")
+	viper.SetDefault("MAIL_DRIVER", "file") // file | smtp
+	viper.SetDefault("MAIL_DIR", "./tmp/mail")
+	viper.SetDefault("MAIL_FROM", "麦芒文学社 <noreply@maimang.com>")
+	viper.SetDefault("NOTIFY_EMAIL_INTERVAL", "5m") // 通知邮件发送任务的执行间隔
+	viper.SetDefault("MESSAGE_UNSEND_WINDOW", "2m") // 私信发送后可撤回的时限
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_REJECT_COMMON", true)
+	viper.SetDefault("LOGIN_EMAIL_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_IP_MAX_FAILURES", 20)
+	viper.SetDefault("LOGIN_LOCKOUT", "15m")
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("MM") // e.g. MM_API_ADDR
 }
 
+// newMailer 按配置创建邮件发送器，开发环境默认写入本地目录
+func newMailer() mailer.Mailer {
+	if viper.GetString("MAIL_DRIVER") == "smtp" {
+		return mailer.NewSMTPMailer(
+			viper.GetString("SMTP_HOST"),
+			viper.GetInt("SMTP_PORT"),
+			viper.GetString("SMTP_USERNAME"),
+			viper.GetString("SMTP_PASSWORD"),
+			viper.GetString("MAIL_FROM"),
+		)
+	}
+	return mailer.NewFileMailer(viper.GetString("MAIL_DIR"), viper.GetString("MAIL_FROM"))
+}
+
 func main() {
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(seedCmd)
+	rootCmd.AddCommand(keysCmd)
+	rootCmd.AddCommand(mockIdPCmd)
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cobra"
+)
+
+// mockIdPCmd 本地开发用的 OIDC 身份提供方：登录页直接填写邮箱即可，不做任何密码校验。
+// 在 config.yaml 中配置 issuer 为 http://localhost:9000、client_id/client_secret 与此一致即可联调。
+var mockIdPCmd = &cobra.Command{
+	Use:   "mock-idp",
+	Short: "Run a local mock OIDC identity provider for development",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		issuer, _ := cmd.Flags().GetString("issuer")
+		clientID, _ := cmd.Flags().GetString("client-id")
+		clientSecret, _ := cmd.Flags().GetString("client-secret")
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+		idp := &mockIdP{
+			issuer:       strings.TrimRight(issuer, "/"),
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			key:          key,
+			codes:        map[string]mockGrant{},
+			tokens:       map[string]mockGrant{},
+		}
+		log.Printf("mock OIDC provider listening on %s (issuer %s, client_id %s)", addr, idp.issuer, clientID)
+		return http.ListenAndServe(addr, idp.routes())
+	},
+}
+
+func init() {
+	mockIdPCmd.Flags().String("addr", ":9000", "listen address")
+	mockIdPCmd.Flags().String("issuer", "http://localhost:9000", "issuer URL as seen by the backend")
+	mockIdPCmd.Flags().String("client-id", "maimang", "accepted client_id")
+	mockIdPCmd.Flags().String("client-secret", "maimang-secret", "accepted client_secret")
+}
+
+type mockIdP struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]mockGrant
+	tokens map[string]mockGrant
+}
+
+type mockGrant struct {
+	RedirectURI   string
+	Nonce         string
+	Challenge     string
+	Subject       string
+	Email         string
+	Name          string
+	EmailVerified bool
+	ExpiresAt     time.Time
+}
+
+const mockKeyID = "mock-idp"
+
+var mockLoginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<meta charset="utf-8"><title>Mock IdP</title>
+<h3>Mock IdP 登录</h3>
+<form method="post">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+<p><label>邮箱 <input name="email" value="{{.Hint}}" required></label></p>
+<p><label>姓名 <input name="name"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true" checked> 邮箱已验证</label></p>
+<button type="submit">登录</button>
+</form>`))
+
+func (m *mockIdP) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/userinfo", m.userinfo)
+	mux.HandleFunc("/jwks", m.jwks)
+	return mux
+}
+
+func (m *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                m.issuer,
+		"authorization_endpoint":                m.issuer + "/authorize",
+		"token_endpoint":                        m.issuer + "/token",
+		"userinfo_endpoint":                     m.issuer + "/userinfo",
+		"jwks_uri":                              m.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("client_id") != m.clientID || r.Form.Get("response_type") != "code" {
+		http.Error(w, "invalid client_id or response_type", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		params := map[string][]string{}
+		for _, k := range []string{"client_id", "response_type", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params[k] = []string{r.Form.Get(k)}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = mockLoginPage.Execute(w, map[string]interface{}{"Params": params, "Hint": r.Form.Get("login_hint")})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(r.PostForm.Get("email")))
+	code := randomString()
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		RedirectURI:   r.Form.Get("redirect_uri"),
+		Nonce:         r.Form.Get("nonce"),
+		Challenge:     r.Form.Get("code_challenge"),
+		Subject:       "mock-" + email,
+		Email:         email,
+		Name:          r.PostForm.Get("name"),
+		EmailVerified: r.PostForm.Get("email_verified") == "true",
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	sep := "?"
+	if strings.Contains(r.Form.Get("redirect_uri"), "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, r.Form.Get("redirect_uri")+sep+"code="+code+"&state="+r.Form.Get("state"), http.StatusFound)
+}
+
+func (m *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != m.clientID || secret != m.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || time.Now().After(grant.ExpiresAt) || grant.RedirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if grant.Challenge != "" && base64.RawURLEncoding.EncodeToString(sum[:]) != grant.Challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "pkce mismatch"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.issuer,
+		"sub":            grant.Subject,
+		"aud":            m.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.Nonce,
+		"email":          grant.Email,
+		"email_verified": grant.EmailVerified,
+		"name":           grant.Name,
+	})
+	idToken.Header["kid"] = mockKeyID
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	access := randomString()
+	m.mu.Lock()
+	grant.ExpiresAt = now.Add(time.Hour)
+	m.tokens[access] = grant
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": access,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func (m *mockIdP) userinfo(w http.ResponseWriter, r *http.Request) {
+	access := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	m.mu.Lock()
+	grant, ok := m.tokens[access]
+	m.mu.Unlock()
+	if !ok || time.Now().After(grant.ExpiresAt) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            grant.Subject,
+		"email":          grant.Email,
+		"email_verified": grant.EmailVerified,
+		"name":           grant.Name,
+	})
+}
+
+func (m *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mockKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("random: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
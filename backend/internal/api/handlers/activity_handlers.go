@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"maimang/backend/internal/notify"
 	"maimang/backend/internal/repo"
 	"maimang/backend/internal/types"
 )
@@ -429,6 +430,7 @@ func UpdateActivity(db *gorm.DB) fiber.Handler {
 			updates["status"] = req.Status
 		}
 
+		previousStatus := activity.Status
 		if err := db.Model(&activity).Updates(updates).Error; err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
@@ -436,6 +438,10 @@ func UpdateActivity(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		if req.Status != "" && repo.ActivityStatus(req.Status) != previousStatus {
+			notify.ActivityStatusChanged(db, &activity, repo.ActivityStatus(req.Status))
+		}
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Activity updated successfully",
@@ -551,6 +557,7 @@ func UpdateActivityStatus(db *gorm.DB) fiber.Handler {
 		}
 
 		// 更新状态
+		previousStatus := activity.Status
 		if err := db.Model(&activity).Update("status", req.Status).Error; err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
@@ -558,6 +565,11 @@ func UpdateActivityStatus(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// 状态确有变化时通知报名者
+		if repo.ActivityStatus(req.Status) != previousStatus {
+			notify.ActivityStatusChanged(db, &activity, repo.ActivityStatus(req.Status))
+		}
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Activity status updated successfully",
@@ -5,12 +5,43 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"maimang/backend/internal/auth"
 	"maimang/backend/internal/repo"
 	"maimang/backend/internal/types"
 )
 
+// 后台返回的用户，附带不对外公开的账号状态
+type adminUser struct {
+	repo.User
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPEnabled     bool       `json:"totp_enabled"`
+	PendingApproval bool       `json:"pending_approval"`
+	ApplicationNote string     `json:"application_note"`
+	DMPolicy        string     `json:"dm_policy"`
+}
+
+func newAdminUser(u repo.User) adminUser {
+	return adminUser{
+		User:            u,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		TOTPEnabled:     u.TOTPEnabled,
+		PendingApproval: u.PendingApproval,
+		ApplicationNote: u.ApplicationNote,
+		DMPolicy:        u.DMPolicy,
+	}
+}
+
+func newAdminUsers(list []repo.User) []adminUser {
+	out := make([]adminUser, len(list))
+	for i, u := range list {
+		out[i] = newAdminUser(u)
+	}
+	return out
+}
+
 // 获取用户统计
 func GetUserStats(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -100,6 +131,23 @@ func GetCommentStats(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// 后台返回的评论，附带不对外公开的自动审核判定原因
+type adminComment struct {
+	repo.Comment
+	ModerationReason string `json:"moderation_reason"`
+}
+
+func newAdminComment(c repo.Comment) adminComment {
+	return adminComment{Comment: c, ModerationReason: c.ModerationReason}
+}
+
+// 评论详情（管理员）：附带编辑历史和审核记录
+type adminCommentDetail struct {
+	adminComment
+	Revisions []repo.CommentRevision  `json:"revisions"`
+	Reviews   []repo.CommentReviewLog `json:"reviews"`
+}
+
 // 获取单条评论（管理员）
 func GetAdminComment(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -109,14 +157,30 @@ func GetAdminComment(db *gorm.DB) fiber.Handler {
 		}
 
 		var comment repo.Comment
-		if err := db.Preload("Author").Preload("Work").First(&comment, commentID).Error; err != nil {
+		if err := db.Preload("Author").Preload("Work").Preload("Reviewer").First(&comment, commentID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return c.Status(404).JSON(types.Response{Success: false, Error: "Comment not found"})
 			}
 			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch comment"})
 		}
 
-		return c.JSON(types.Response{Success: true, Data: comment})
+		// 修改记录和审核记录，均按时间正序
+		var revisions []repo.CommentRevision
+		if err := db.Where("comment_id = ?", comment.ID).Preload("Editor").
+			Order("id ASC").Find(&revisions).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch comment history"})
+		}
+		var reviews []repo.CommentReviewLog
+		if err := db.Where("comment_id = ?", comment.ID).Preload("Reviewer").
+			Order("id ASC").Find(&reviews).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch comment history"})
+		}
+
+		return c.JSON(types.Response{Success: true, Data: adminCommentDetail{
+			adminComment: newAdminComment(comment),
+			Revisions:    revisions,
+			Reviews:      reviews,
+		}})
 	}
 }
 
@@ -181,7 +245,7 @@ func ListUsers(db *gorm.DB) fiber.Handler {
 
 		return c.JSON(types.PaginatedResponse{
 			Success: true,
-			Data:    users,
+			Data:    newAdminUsers(users),
 			Meta: types.PaginationMeta{
 				Page:       query.Page,
 				PerPage:    query.PerPage,
@@ -227,13 +291,24 @@ func UpdateUserStatus(db *gorm.DB) fiber.Handler {
 		}
 
 		// 更新状态
-		if err := db.Model(&user).Update("status", req.Status).Error; err != nil {
+		// 直接激活待审核账号等同于通过审核
+		updates := map[string]interface{}{"status": req.Status}
+		if req.Status == "active" {
+			updates["pending_approval"] = false
+		}
+		if err := db.Model(&user).Updates(updates).Error; err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
 				Error:   "Failed to update user status",
 			})
 		}
 
+		// 非活跃状态的用户立即失去已签发的令牌
+		auth.InvalidateUserState(user.ID)
+		if req.Status != "active" {
+			_ = auth.RevokeUserRefreshTokens(db, user.ID)
+		}
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "User status updated successfully",
@@ -258,7 +333,7 @@ func ListAdmins(db *gorm.DB) fiber.Handler {
 
 		return c.JSON(types.Response{
 			Success: true,
-			Data:    admins,
+			Data:    newAdminUsers(admins),
 		})
 	}
 }
@@ -283,11 +358,26 @@ func CreateAdmin(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// 校验密码策略并加密
+		if err := auth.ValidatePassword(req.Password, req.Email, req.Name); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to hash password",
+			})
+		}
+
 		// 创建管理员
 		admin := repo.User{
 			Name:     req.Name,
 			Email:    req.Email,
-			Password: req.Password, // 注意：这里应该加密密码
+			Password: string(hashed),
 			Role:     repo.Role(req.Role),
 			Status:   "active",
 		}
@@ -368,6 +458,9 @@ func UpdateAdmin(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// 角色变更对后续请求立即生效
+		auth.InvalidateUserState(admin.ID)
+
 		// 清除密码字段
 		admin.Password = ""
 
@@ -421,6 +514,9 @@ func DeleteAdmin(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		auth.InvalidateUserState(admin.ID)
+		_ = auth.RevokeUserRefreshTokens(db, admin.ID)
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Admin deleted successfully",
@@ -487,6 +583,10 @@ func BanUser(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// 封禁立即生效，并吊销其所有登录会话
+		auth.InvalidateUserState(user.ID)
+		_ = auth.RevokeUserRefreshTokens(db, user.ID)
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "User banned successfully",
@@ -532,6 +632,8 @@ func UnbanUser(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		auth.InvalidateUserState(user.ID)
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "User unbanned successfully",
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,18 +12,23 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"maimang/backend/internal/auth"
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/rbac"
 	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
 
 	"gorm.io/gorm"
 )
 
 type registerReq struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	InviteCode string `json:"invite_code"` // 邀请注册模式下必填
+	Note       string `json:"note"`        // 审核注册模式下的申请说明
 }
 
-func Register(db *gorm.DB) fiber.Handler {
+func Register(db *gorm.DB, m mailer.Mailer) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		log.Printf("=== Register API Called ===")
 		log.Printf("Request Method: %s", c.Method())
@@ -43,6 +50,16 @@ func Register(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "missing fields"})
 		}
 
+		if err := auth.ValidatePassword(req.Password, req.Email, req.Name); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		mode := settings.Registration(db)
+		req.InviteCode = strings.TrimSpace(req.InviteCode)
+		if mode == settings.RegistrationInvite && req.InviteCode == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invite code required"})
+		}
+
 		log.Printf("Generating password hash...")
 		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -51,15 +68,48 @@ func Register(db *gorm.DB) fiber.Handler {
 		}
 
 		user := repo.User{Name: req.Name, Email: req.Email, Password: string(hashed), Role: repo.RoleMember}
+		// 审核模式：账号先保持未激活，等待管理员审核
+		if mode == settings.RegistrationApproval {
+			user.Status = "inactive"
+			user.PendingApproval = true
+			user.ApplicationNote = truncateRunes(strings.TrimSpace(req.Note), 1000)
+		}
 		log.Printf("Creating user: %+v", user)
 
-		if err := db.Create(&user).Error; err != nil {
+		var exists int64
+		db.Model(&repo.User{}).Where("email = ?", req.Email).Count(&exists)
+		if exists > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "email exists"})
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if mode == settings.RegistrationInvite {
+				inviteID, err := redeemInviteCode(tx, req.InviteCode)
+				if err != nil {
+					return err
+				}
+				user.InviteCodeID = &inviteID
+			}
+			return tx.Create(&user).Error
+		})
+		if err != nil {
+			if errors.Is(err, errInviteInvalid) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid invite code"})
+			}
 			log.Printf("Database create error: %v", err)
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "email exists"})
 		}
 
 		log.Printf("User created successfully: ID=%d, Email=%s, Name=%s", user.ID, user.Email, user.Name)
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"id": user.ID, "email": user.Email, "name": user.Name})
+
+		// 发送邮箱验证邮件（失败不影响注册）
+		sendVerificationEmail(db, m, &user)
+
+		resp := fiber.Map{"id": user.ID, "email": user.Email, "name": user.Name}
+		if user.PendingApproval {
+			resp["pending_approval"] = true
+		}
+		return c.Status(fiber.StatusCreated).JSON(resp)
 	}
 }
 
@@ -75,38 +125,63 @@ func Login(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
 		}
 		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+
+		// 失败过多的邮箱或 IP 需要等待退避时间或锁定结束
+		if loginThrottled(c, db, req.Email) {
+			return nil
+		}
+
 		var user repo.User
 		if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid credentials"})
+			return loginFailed(c, db, req.Email)
 		}
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid credentials"})
+			return loginFailed(c, db, req.Email)
 		}
-		ttl := viper.GetDuration("ACCESS_TOKEN_TTL")
-		if ttl == 0 {
-			ttl = time.Hour * 2
+		if err := auth.RecordLoginSuccess(db, req.Email); err != nil {
+			log.Printf("Reset login throttle error: %v", err)
 		}
-		token, err := auth.GenerateAccessToken(viper.GetString("JWT_SECRET"), user.ID, string(user.Role), ttl)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
-		}
-		rttl := viper.GetDuration("REFRESH_TOKEN_TTL")
-		if rttl == 0 {
-			rttl = time.Hour * 24 * 7
-		}
-		rt, err := auth.GenerateRefreshToken(viper.GetString("JWT_SECRET"), user.ID, string(user.Role), rttl)
+		return completeLogin(c, db, &user)
+	}
+}
+
+// completeLogin 身份确认后的共同步骤：检查账号状态，需要时发起两步验证，否则签发令牌
+func completeLogin(c *fiber.Ctx, db *gorm.DB, user *repo.User) error {
+	if user.PendingApproval {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "account pending approval", "pending_approval": true})
+	}
+	if user.Status != "active" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "account disabled"})
+	}
+
+	// 已启用两步验证：先返回临时令牌，由 /auth/login/mfa 完成登录
+	if user.TOTPEnabled {
+		mfaToken, err := auth.GenerateMFAToken(user.ID, mfaTokenTTL)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
 		}
-		return c.JSON(fiber.Map{"access_token": token, "refresh_token": rt})
+		return c.JSON(fiber.Map{"mfa_required": true, "mfa_token": mfaToken})
+	}
+
+	tokens, err := issueTokens(c, db, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
+	}
+	if twoFactorRequired(db, user) {
+		tokens["mfa_enrollment_required"] = true
 	}
+	return c.JSON(tokens)
 }
 
-func Me() fiber.Handler {
+func Me(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		uid := c.Locals("uid")
-		role := c.Locals("role")
-		return c.JSON(fiber.Map{"uid": uid, "role": role})
+		role, _ := c.Locals("role").(string)
+		perms, err := rbac.Permissions(db, repo.Role(role))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "permission lookup failed"})
+		}
+		return c.JSON(fiber.Map{"uid": uid, "role": role, "permissions": perms})
 	}
 }
 
@@ -114,24 +189,309 @@ type refreshReq struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
-func Refresh() fiber.Handler {
+// Refresh 轮换刷新令牌：旧令牌作废并签发同一家族的新令牌；重复使用旧令牌会吊销整个家族
+func Refresh(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req refreshReq
 		if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
 		}
-		claims, err := auth.ParseToken(viper.GetString("JWT_SECRET"), req.RefreshToken)
-		if err != nil || !auth.IsRefresh(claims) {
+		rt, stored, err := auth.RotateRefreshToken(db, req.RefreshToken, refreshTTL())
+		if err != nil {
+			if errors.Is(err, auth.ErrRefreshReused) {
+				log.Printf("Refresh token reuse detected, family revoked")
+			}
+			if errors.Is(err, auth.ErrRefreshInvalid) || errors.Is(err, auth.ErrRefreshReused) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
+		}
+
+		// 以数据库中的当前角色和状态签发访问令牌
+		var user repo.User
+		if err := db.First(&user, stored.UserID).Error; err != nil {
+			_ = auth.RevokeRefreshFamily(db, stored.FamilyID)
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
 		}
-		ttl := viper.GetDuration("ACCESS_TOKEN_TTL")
-		if ttl == 0 {
-			ttl = time.Hour * 2
+		if user.Status != "active" {
+			_ = auth.RevokeRefreshFamily(db, stored.FamilyID)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "account disabled"})
 		}
-		at, err := auth.GenerateAccessToken(viper.GetString("JWT_SECRET"), claims.UserID, claims.Role, ttl)
+		if err := auth.ExtendSession(db, stored.FamilyID, user.ID, c.Get(fiber.HeaderUserAgent), c.IP(), stored.ExpiresAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
+		}
+		at, err := auth.GenerateAccessToken(user.ID, string(user.Role), user.TokenVersion, stored.FamilyID, accessTTL())
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
 		}
-		return c.JSON(fiber.Map{"access_token": at})
+		return c.JSON(fiber.Map{"access_token": at, "refresh_token": rt})
+	}
+}
+
+// Logout 吊销当前刷新令牌所属的整个家族（即当前登录会话）
+func Logout(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req refreshReq
+		if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
+		}
+		stored, err := auth.LookupRefreshToken(db, req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// 未知令牌按已退出处理，保持幂等
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "logout failed"})
+		}
+		if err := auth.RevokeRefreshFamily(db, stored.FamilyID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "logout failed"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// LogoutAll 吊销当前用户在所有设备上的刷新令牌和访问令牌
+func LogoutAll(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		if err := auth.RevokeUserRefreshTokens(db, uid); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "logout failed"})
+		}
+		if err := auth.BumpTokenVersion(db, uid); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "logout failed"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+type forgotPasswordReq struct {
+	Email string `json:"email"`
+}
+
+// ForgotPassword 发送找回密码邮件；无论邮箱是否存在都返回相同结果，避免枚举账号
+func ForgotPassword(db *gorm.DB, m mailer.Mailer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req forgotPasswordReq
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
+		}
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		if req.Email == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "missing fields"})
+		}
+
+		var user repo.User
+		if err := db.Where("email = ?", req.Email).First(&user).Error; err == nil && user.Status != "banned" {
+			token, err := auth.IssueOneTimeToken(db, user.ID, repo.TokenPasswordReset, viper.GetDuration("PASSWORD_RESET_TTL"))
+			if err != nil {
+				log.Printf("Issue password reset token error: %v", err)
+			} else {
+				link := viper.GetString("APP_BASE_URL") + "/reset-password?token=" + token
+				sendMailAsync(m, mailer.Message{
+					To:      []string{user.Email},
+					Subject: "麦芒文学社 - 重置密码",
+					Body: "你好，" + user.Name + "：\n\n我们收到了重置密码的请求。请在 " +
+						viper.GetDuration("PASSWORD_RESET_TTL").String() + " 内打开以下链接设置新密码：\n\n" +
+						link + "\n\n如果这不是你本人的操作，请忽略本邮件。\n",
+				})
+			}
+		}
+
+		return c.JSON(fiber.Map{"message": "if the email is registered, a reset link has been sent"})
+	}
+}
+
+type resetPasswordReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ResetPassword 使用找回密码令牌设置新密码，并让该用户所有已登录会话失效
+func ResetPassword(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req resetPasswordReq
+		if err := c.BodyParser(&req); err != nil || req.Token == "" || req.Password == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
+		}
+
+		if err := auth.ValidatePassword(req.Password); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "hash error"})
+		}
+
+		uid, err := auth.ConsumeOneTimeToken(db, req.Token, repo.TokenPasswordReset)
+		if err != nil {
+			if errors.Is(err, auth.ErrOneTimeTokenInvalid) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid or expired token"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "reset failed"})
+		}
+
+		// 能收到重置邮件即证明拥有该邮箱
+		updates := map[string]interface{}{
+			"password":          string(hashed),
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, NOW())"),
+		}
+		if err := db.Model(&repo.User{}).Where("id = ?", uid).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "reset failed"})
+		}
+		// 密码可能已泄露，个人访问令牌一并吊销
+		if err := auth.RevokeUserRefreshTokens(db, uid); err != nil {
+			log.Printf("Revoke refresh tokens error: %v", err)
+		}
+		if err := auth.RevokeUserAPITokens(db, uid); err != nil {
+			log.Printf("Revoke API tokens error: %v", err)
+		}
+		if err := auth.BumpTokenVersion(db, uid); err != nil {
+			log.Printf("Bump token version error: %v", err)
+		}
+
+		return c.JSON(fiber.Map{"message": "password reset"})
+	}
+}
+
+type verifyEmailReq struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmail 核销邮箱验证令牌
+func VerifyEmail(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req verifyEmailReq
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
+		}
+		uid, err := auth.ConsumeOneTimeToken(db, req.Token, repo.TokenEmailVerify)
+		if err != nil {
+			if errors.Is(err, auth.ErrOneTimeTokenInvalid) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid or expired token"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "verify failed"})
+		}
+		if err := db.Model(&repo.User{}).Where("id = ? AND email_verified_at IS NULL", uid).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "verify failed"})
+		}
+		return c.JSON(fiber.Map{"message": "email verified"})
+	}
+}
+
+// ResendVerification 重新发送邮箱验证邮件（登录用户）
+func ResendVerification(db *gorm.DB, m mailer.Mailer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		var user repo.User
+		if err := db.First(&user, uid).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+		}
+		if user.EmailVerifiedAt != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "email already verified"})
+		}
+		sendVerificationEmail(db, m, &user)
+		return c.JSON(fiber.Map{"message": "verification email sent"})
+	}
+}
+
+// sendVerificationEmail 签发邮箱验证令牌并异步发送邮件
+func sendVerificationEmail(db *gorm.DB, m mailer.Mailer, user *repo.User) {
+	token, err := auth.IssueOneTimeToken(db, user.ID, repo.TokenEmailVerify, viper.GetDuration("EMAIL_VERIFY_TTL"))
+	if err != nil {
+		log.Printf("Issue email verification token error: %v", err)
+		return
+	}
+	link := viper.GetString("APP_BASE_URL") + "/verify-email?token=" + token
+	sendMailAsync(m, mailer.Message{
+		To:      []string{user.Email},
+		Subject: "麦芒文学社 - 验证邮箱",
+		Body: "你好，" + user.Name + "：\n\n欢迎加入麦芒文学社！请打开以下链接验证你的邮箱：\n\n" +
+			link + "\n\n如果你没有注册过麦芒文学社账号，请忽略本邮件。\n",
+	})
+}
+
+// sendMailAsync 在后台发送邮件，避免阻塞请求并隐藏发送耗时
+func sendMailAsync(m mailer.Mailer, msg mailer.Message) {
+	go func() {
+		if err := m.Send(msg); err != nil {
+			log.Printf("Send mail to %v error: %v", msg.To, err)
+		}
+	}()
+}
+
+// loginThrottled 登录尝试被限制时写入 429 响应并返回 true
+func loginThrottled(c *fiber.Ctx, db *gorm.DB, email string) bool {
+	wait, err := auth.CheckLoginThrottle(db, email, c.IP())
+	if err != nil {
+		log.Printf("Check login throttle error: %v", err)
+		return false
+	}
+	if wait <= 0 {
+		return false
+	}
+	secs := int(wait.Seconds() + 0.999)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(secs))
+	_ = c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "too many failed attempts",
+		"retry_after": secs,
+	})
+	return true
+}
+
+// loginFailed 记录失败的登录尝试并返回统一的错误
+func loginFailed(c *fiber.Ctx, db *gorm.DB, email string) error {
+	locked, err := auth.RecordLoginFailure(db, email, c.IP())
+	if err != nil {
+		log.Printf("Record login failure error: %v", err)
+	}
+	if locked {
+		log.Printf("Login locked out: email=%s ip=%s", email, c.IP())
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid credentials"})
+}
+
+// JWKS 公开访问令牌的校验公钥，供其他服务独立校验令牌
+func JWKS() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ks := auth.CurrentKeySet()
+		if ks == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "no keys loaded"})
+		}
+		c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(auth.JWKSMaxAge.Seconds())))
+		return c.JSON(ks.JWKS())
+	}
+}
+
+// issueTokens 开启新的登录会话并签发访问令牌和刷新令牌，会话 ID 即刷新令牌的 FamilyID
+func issueTokens(c *fiber.Ctx, db *gorm.DB, user *repo.User) (fiber.Map, error) {
+	session, err := auth.StartSession(db, user.ID, c.Get(fiber.HeaderUserAgent), c.IP(), refreshTTL())
+	if err != nil {
+		return nil, err
+	}
+	at, err := auth.GenerateAccessToken(user.ID, string(user.Role), user.TokenVersion, session.ID, accessTTL())
+	if err != nil {
+		return nil, err
+	}
+	rt, _, err := auth.IssueRefreshToken(db, user.ID, session.ID, refreshTTL())
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{"access_token": at, "refresh_token": rt}, nil
+}
+
+func accessTTL() time.Duration {
+	ttl := viper.GetDuration("ACCESS_TOKEN_TTL")
+	if ttl == 0 {
+		ttl = time.Hour * 2
+	}
+	return ttl
+}
+
+func refreshTTL() time.Duration {
+	ttl := viper.GetDuration("REFRESH_TOKEN_TTL")
+	if ttl == 0 {
+		ttl = time.Hour * 24 * 7
 	}
+	return ttl
 }
@@ -1,12 +1,23 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"maimang/backend/internal/mention"
+	"maimang/backend/internal/moderation"
+	"maimang/backend/internal/notify"
+	"maimang/backend/internal/rbac"
 	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
 	"maimang/backend/internal/types"
 )
 
@@ -41,15 +52,22 @@ func ListWorkComments(db *gorm.DB) fiber.Handler {
 		var total int64
 
 		// 构建查询
-		tx := db.Model(&repo.Comment{}).Where("work_id = ?", workID).Preload("Author")
+		// 只分页顶层评论，回复折叠为 Replies 计数，通过 /comments/:id/replies 展开
+		tx := db.Model(&repo.Comment{}).Where("work_id = ? AND parent_id IS NULL", workID).
+			Preload("Author").Preload("Mentions")
+
+		// 只公开已通过的评论；登录用户还能看到自己待审核等状态的评论，看不到自己拉黑的用户的评论
+		if uid, ok := currentUserID(c); ok {
+			tx = tx.Where("status = ? OR author_id = ?", repo.CommentApproved, uid).
+				Where("author_id NOT IN (?)", blockedBy(db, uid))
+		} else {
+			tx = tx.Where("status = ?", repo.CommentApproved)
+		}
 
 		// 搜索条件
 		if query.Search != "" {
 			tx = tx.Where("content ILIKE ?", "%"+query.Search+"%")
 		}
-		if query.Status != "" {
-			tx = tx.Where("status = ?", query.Status)
-		}
 
 		// 获取总数
 		tx.Count(&total)
@@ -68,6 +86,10 @@ func ListWorkComments(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		if uid, ok := currentUserID(c); ok {
+			markLikedComments(db, uid, comments)
+		}
+
 		// 计算总页数
 		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
 
@@ -96,7 +118,7 @@ func CreateComment(db *gorm.DB) fiber.Handler {
 		}
 
 		var req types.CreateCommentRequest
-		if err := c.BodyParser(&req); err != nil {
+		if err := c.BodyParser(&req); err != nil || !normalizeCommentContent(&req.Content) {
 			return c.Status(400).JSON(types.Response{
 				Success: false,
 				Error:   "Invalid request body",
@@ -123,10 +145,10 @@ func CreateComment(db *gorm.DB) fiber.Handler {
 
 		comment := repo.Comment{
 			Content:  req.Content,
-			Status:   repo.CommentPending,
 			AuthorID: userID,
 			WorkID:   uint(workID),
 		}
+		moderateComment(db, &comment)
 
 		if err := db.Create(&comment).Error; err != nil {
 			return c.Status(500).JSON(types.Response{
@@ -134,18 +156,276 @@ func CreateComment(db *gorm.DB) fiber.Handler {
 				Error:   "Failed to create comment",
 			})
 		}
+		logCommentReview(db, comment.ID, nil, "auto", "", comment.Status, comment.ModerationReason)
+		mention.Sync(db, mention.TargetComment, comment.ID, userID, comment.Content)
+		if comment.Status == repo.CommentApproved {
+			notify.CommentPublished(db, &comment)
+		}
 
 		// 预加载作者信息
-		db.Preload("Author").First(&comment, comment.ID)
+		db.Preload("Author").Preload("Mentions").First(&comment, comment.ID)
 
 		return c.Status(201).JSON(types.Response{
 			Success: true,
-			Message: "Comment created successfully",
+			Message: moderationMessage(comment.Status),
 			Data:    comment,
 		})
 	}
 }
 
+// 获取评论的回复列表，按时间正序；未公开的评论按不存在处理
+func ListCommentReplies(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid comment ID",
+			})
+		}
+
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		// 有审核权限的用户可查看全部状态并按状态筛选；其他人只能展开已公开评论下的回复
+		uid, loggedIn := currentUserID(c)
+		reviewer := false
+		if loggedIn {
+			role, _ := c.Locals("role").(string)
+			reviewer, _ = rbac.Has(db, repo.Role(role), rbac.CommentsReview)
+		}
+		if !reviewer {
+			var parent repo.Comment
+			if err := db.Select("id").Where("status = ?", repo.CommentApproved).First(&parent, commentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Status(404).JSON(types.Response{
+						Success: false,
+						Error:   "Comment not found",
+					})
+				}
+				return c.Status(500).JSON(types.Response{
+					Success: false,
+					Error:   "Failed to fetch comment",
+				})
+			}
+		}
+
+		var replies []repo.Comment
+		var total int64
+
+		// 构建查询
+		tx := db.Model(&repo.Comment{}).Where("parent_id = ?", commentID).
+			Preload("Author").Preload("ReplyToUser").Preload("Mentions")
+
+		switch {
+		case reviewer:
+			if query.Status != "" {
+				tx = tx.Where("status = ?", query.Status)
+			}
+		case loggedIn:
+			// 自己的回复在审核通过前也能看到
+			tx = tx.Where("status = ? OR author_id = ?", repo.CommentApproved, uid)
+		default:
+			tx = tx.Where("status = ?", repo.CommentApproved)
+		}
+		// 登录用户看不到自己拉黑的用户的回复
+		if loggedIn {
+			tx = tx.Where("author_id NOT IN (?)", blockedBy(db, uid))
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("created_at ASC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&replies)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch replies",
+			})
+		}
+
+		if loggedIn {
+			markLikedComments(db, uid, replies)
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    replies,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// 回复评论；回复的回复挂在同一条顶层评论下
+func CreateReply(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid comment ID",
+			})
+		}
+
+		var req types.CreateCommentRequest
+		if err := c.BodyParser(&req); err != nil || !normalizeCommentContent(&req.Content) {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+
+		// 获取当前用户ID
+		userID := c.Locals("uid").(uint)
+
+		// 只能回复已公开的评论
+		var target repo.Comment
+		if err := db.Where("status = ?", repo.CommentApproved).First(&target, commentID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Comment not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch comment",
+			})
+		}
+
+		// 被回复者拉黑了自己时不能回复
+		if blocked, err := isBlockedEither(db, userID, target.AuthorID); err != nil || blocked {
+			return c.Status(403).JSON(types.Response{
+				Success: false,
+				Error:   "Cannot reply to this comment",
+			})
+		}
+
+		parentID := target.ID
+		if target.ParentID != nil {
+			parentID = *target.ParentID
+		}
+		reply := repo.Comment{
+			Content:       req.Content,
+			AuthorID:      userID,
+			WorkID:        target.WorkID,
+			ParentID:      &parentID,
+			ReplyToUserID: &target.AuthorID,
+		}
+		moderateComment(db, &reply)
+
+		if err := db.Create(&reply).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to create reply",
+			})
+		}
+		logCommentReview(db, reply.ID, nil, "auto", "", reply.Status, reply.ModerationReason)
+		mention.Sync(db, mention.TargetComment, reply.ID, userID, reply.Content)
+		if reply.Status == repo.CommentApproved {
+			refreshReplyCount(db, reply.ParentID)
+			notify.CommentPublished(db, &reply)
+		}
+
+		// 预加载作者信息
+		db.Preload("Author").Preload("ReplyToUser").Preload("Mentions").First(&reply, reply.ID)
+
+		return c.Status(201).JSON(types.Response{
+			Success: true,
+			Message: moderationMessage(reply.Status),
+			Data:    reply,
+		})
+	}
+}
+
+// 评论内容的最大字符数，与请求类型上的 validate 标签一致
+const maxCommentLength = 2000
+
+// normalizeCommentContent 去掉评论内容首尾空白，内容为空或超长时返回 false
+func normalizeCommentContent(content *string) bool {
+	*content = strings.TrimSpace(*content)
+	n := utf8.RuneCountInString(*content)
+	return n > 0 && n <= maxCommentLength
+}
+
+// moderateComment 对待创建的评论运行自动审核，写入判定的状态和原因
+func moderateComment(db *gorm.DB, comment *repo.Comment) {
+	d := moderation.CheckComment(db, comment)
+	comment.Status = d.Status
+	comment.ModerationReason = d.Reason
+	comment.AutoModerated = true
+	if d.Status != repo.CommentPending {
+		now := time.Now()
+		comment.ReviewedAt = &now
+	}
+}
+
+// moderationMessage 按自动审核结果提示用户
+func moderationMessage(status repo.CommentStatus) string {
+	switch status {
+	case repo.CommentApproved:
+		return "Comment published"
+	case repo.CommentRejected:
+		return "Comment rejected by automatic moderation"
+	}
+	return "Comment submitted for review"
+}
+
+// logCommentReview 记录一次审核判定；reviewerID 为空表示自动审核。失败只记录日志
+func logCommentReview(db *gorm.DB, commentID uint, reviewerID *uint, action string, from, to repo.CommentStatus, note string) {
+	entry := repo.CommentReviewLog{
+		CommentID:  commentID,
+		ReviewerID: reviewerID,
+		Action:     action,
+		FromStatus: from,
+		ToStatus:   to,
+		Note:       note,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Create comment review log error: %v", err)
+	}
+}
+
+// refreshReplyCount 重新统计顶层评论已公开的回复数；回复审核状态变化或被删除后调用
+func refreshReplyCount(db *gorm.DB, parentID *uint) {
+	if parentID == nil {
+		return
+	}
+	count := db.Model(&repo.Comment{}).Select("COUNT(*)").
+		Where("parent_id = ? AND status = ?", *parentID, repo.CommentApproved)
+	if err := db.Model(&repo.Comment{}).Where("id = ?", *parentID).
+		UpdateColumn("replies", count).Error; err != nil {
+		log.Printf("Refresh reply count error: %v", err)
+	}
+}
+
 // 更新评论
 func UpdateComment(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -158,7 +438,7 @@ func UpdateComment(db *gorm.DB) fiber.Handler {
 		}
 
 		var req types.UpdateCommentRequest
-		if err := c.BodyParser(&req); err != nil {
+		if err := c.BodyParser(&req); err != nil || !normalizeCommentContent(&req.Content) {
 			return c.Status(400).JSON(types.Response{
 				Success: false,
 				Error:   "Invalid request body",
@@ -191,16 +471,76 @@ func UpdateComment(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// 更新内容
-		if err := db.Model(&comment).Update("content", req.Content).Error; err != nil {
+		// 内容未变化时不产生修改记录
+		if req.Content == comment.Content {
+			db.Preload("Author").Preload("Mentions").First(&comment, comment.ID)
+			return c.JSON(types.Response{
+				Success: true,
+				Message: "Comment updated successfully",
+				Data:    comment,
+			})
+		}
+
+		// 更新内容；待审核和已公开的评论按新内容重新自动审核，被人工拒绝或隐藏的保持原状态。
+		// 已公开的评论默认编辑后重新进入待审核，避免通过审核后再改成违规内容
+		previousStatus := comment.Status
+		revision := repo.CommentRevision{
+			CommentID: comment.ID,
+			EditorID:  userID,
+			Content:   comment.Content,
+			Status:    previousStatus,
+		}
+		updates := map[string]interface{}{"content": req.Content}
+		var decision *moderation.Decision
+		if previousStatus == repo.CommentPending || previousStatus == repo.CommentApproved {
+			comment.Content = req.Content
+			d := moderation.CheckComment(db, &comment)
+			if d.Status == repo.CommentApproved && previousStatus == repo.CommentApproved &&
+				settings.Bool(db, settings.CommentEditRequiresReview, true) {
+				d = moderation.Decision{Status: repo.CommentPending, Reason: "已公开的评论被编辑，等待重新审核"}
+			}
+			decision = &d
+			updates["status"] = d.Status
+			updates["moderation_reason"] = d.Reason
+			updates["auto_moderated"] = true
+			if d.Status != previousStatus {
+				updates["reviewed_by"] = nil
+				updates["reviewed_at"] = nil
+				if d.Status != repo.CommentPending {
+					updates["reviewed_at"] = time.Now()
+				}
+			}
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+			return tx.Model(&comment).Updates(updates).Error
+		})
+		if err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
 				Error:   "Failed to update comment",
 			})
 		}
+		if decision != nil {
+			logCommentReview(db, comment.ID, nil, "auto", previousStatus, decision.Status, decision.Reason)
+		}
+
+		// 重新解析提及；评论因此公开时按新评论通知，已公开的只通知新提到的人
+		mention.Sync(db, mention.TargetComment, comment.ID, userID, req.Content)
+		if comment.Status != previousStatus {
+			refreshReplyCount(db, comment.ParentID)
+		}
+		switch {
+		case comment.Status == repo.CommentApproved && previousStatus == repo.CommentPending:
+			notify.CommentPublished(db, &comment)
+		case comment.Status == repo.CommentApproved:
+			notify.CommentMentions(db, &comment)
+		}
 
 		// 预加载作者信息
-		db.Preload("Author").First(&comment, comment.ID)
+		db.Preload("Author").Preload("Mentions").First(&comment, comment.ID)
 
 		return c.JSON(types.Response{
 			Success: true,
@@ -254,6 +594,7 @@ func DeleteComment(db *gorm.DB) fiber.Handler {
 				Error:   "Failed to delete comment",
 			})
 		}
+		refreshReplyCount(db, comment.ParentID)
 
 		return c.JSON(types.Response{
 			Success: true,
@@ -262,7 +603,7 @@ func DeleteComment(db *gorm.DB) fiber.Handler {
 	}
 }
 
-// 点赞评论
+// 点赞评论（幂等：重复点赞不会重复计数）
 func LikeComment(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		commentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -273,9 +614,31 @@ func LikeComment(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// 检查评论是否存在
+		userID := c.Locals("uid").(uint)
+
 		var comment repo.Comment
-		if err := db.First(&comment, commentID).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&comment, commentID).Error; err != nil {
+				return err
+			}
+
+			// 唯一索引保证同一用户只记录一次点赞
+			like := repo.CommentLike{CommentID: comment.ID, UserID: userID}
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&like)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+
+			// 在数据库中原子递增，避免并发覆盖
+			if err := tx.Model(&comment).UpdateColumn("likes", gorm.Expr("likes + 1")).Error; err != nil {
+				return err
+			}
+			return tx.Select("likes").First(&comment, comment.ID).Error
+		})
+		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return c.Status(404).JSON(types.Response{
 					Success: false,
@@ -284,25 +647,102 @@ func LikeComment(db *gorm.DB) fiber.Handler {
 			}
 			return c.Status(500).JSON(types.Response{
 				Success: false,
-				Error:   "Failed to fetch comment",
+				Error:   "Failed to like comment",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Comment liked successfully",
+			Data: fiber.Map{
+				"liked": true,
+				"likes": comment.Likes,
+			},
+		})
+	}
+}
+
+// 取消点赞评论（幂等：未点赞时不会改变计数）
+func UnlikeComment(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid comment ID",
 			})
 		}
 
-		// 增加点赞数
-		if err := db.Model(&comment).Update("likes", comment.Likes+1).Error; err != nil {
+		userID := c.Locals("uid").(uint)
+
+		var comment repo.Comment
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&comment, commentID).Error; err != nil {
+				return err
+			}
+
+			res := tx.Where("comment_id = ? AND user_id = ?", comment.ID, userID).Delete(&repo.CommentLike{})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+
+			if err := tx.Model(&comment).UpdateColumn("likes", gorm.Expr("GREATEST(likes - 1, 0)")).Error; err != nil {
+				return err
+			}
+			return tx.Select("likes").First(&comment, comment.ID).Error
+		})
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Comment not found",
+				})
+			}
 			return c.Status(500).JSON(types.Response{
 				Success: false,
-				Error:   "Failed to like comment",
+				Error:   "Failed to unlike comment",
 			})
 		}
 
 		return c.JSON(types.Response{
 			Success: true,
-			Message: "Comment liked successfully",
+			Message: "Comment unliked successfully",
+			Data: fiber.Map{
+				"liked": false,
+				"likes": comment.Likes,
+			},
 		})
 	}
 }
 
+// markLikedComments 为评论列表填充当前用户的点赞状态
+func markLikedComments(db *gorm.DB, userID uint, comments []repo.Comment) {
+	if len(comments) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(comments))
+	for _, cm := range comments {
+		ids = append(ids, cm.ID)
+	}
+
+	var likedIDs []uint
+	if err := db.Model(&repo.CommentLike{}).
+		Where("user_id = ? AND comment_id IN ?", userID, ids).
+		Pluck("comment_id", &likedIDs).Error; err != nil {
+		return
+	}
+	liked := make(map[uint]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range comments {
+		comments[i].LikedByMe = liked[comments[i].ID]
+	}
+}
+
 // 获取待审核评论列表（管理员）
 func ListPendingComments(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -332,10 +772,21 @@ func ListPendingComments(db *gorm.DB) fiber.Handler {
 		if query.Search != "" {
 			tx = tx.Where("content ILIKE ?", "%"+query.Search+"%")
 		}
-		if query.Status != "" {
-			// 指定了状态时才按状态过滤；未指定时显示全部状态
+		// 默认只看待审核的评论；status=all 显示全部状态
+		switch query.Status {
+		case "":
+			tx = tx.Where("status = ?", repo.CommentPending)
+		case "all":
+		default:
 			tx = tx.Where("status = ?", query.Status)
 		}
+		// type=auto 只看系统自动判定的，type=manual 只看人工处理的
+		switch query.Type {
+		case "auto":
+			tx = tx.Where("auto_moderated = ?", true)
+		case "manual":
+			tx = tx.Where("auto_moderated = ?", false)
+		}
 
 		// 获取总数
 		tx.Count(&total)
@@ -354,12 +805,17 @@ func ListPendingComments(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		list := make([]adminComment, len(comments))
+		for i, comment := range comments {
+			list[i] = newAdminComment(comment)
+		}
+
 		// 计算总页数
 		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
 
 		return c.JSON(types.PaginatedResponse{
 			Success: true,
-			Data:    comments,
+			Data:    list,
 			Meta: types.PaginationMeta{
 				Page:       query.Page,
 				PerPage:    query.PerPage,
@@ -389,6 +845,13 @@ func ReviewComment(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		if len([]rune(req.Note)) > 1000 {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Note is too long",
+			})
+		}
+
 		// 获取当前用户ID（审核员）
 		reviewerID := c.Locals("uid").(uint)
 
@@ -409,7 +872,9 @@ func ReviewComment(db *gorm.DB) fiber.Handler {
 
 		// 更新审核状态
 		updates := map[string]interface{}{
-			"reviewed_by": reviewerID,
+			"reviewed_by":    reviewerID,
+			"review_note":    req.Note,
+			"auto_moderated": false,
 		}
 
 		switch req.Action {
@@ -428,8 +893,17 @@ func ReviewComment(db *gorm.DB) fiber.Handler {
 		case "pend":
 			updates["status"] = repo.CommentPending
 			updates["reviewed_at"] = gorm.Expr("NOW()")
+		default:
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid review action",
+			})
 		}
 
+		previousStatus := comment.Status
+		// 被自动审核拒绝的评论从未公开过，人工通过时同样按新评论通知
+		neverPublished := previousStatus == repo.CommentPending ||
+			(previousStatus == repo.CommentRejected && comment.AutoModerated)
 		if err := db.Model(&comment).Updates(updates).Error; err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
@@ -437,6 +911,16 @@ func ReviewComment(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		logCommentReview(db, comment.ID, &reviewerID, req.Action, previousStatus, comment.Status, req.Note)
+		if comment.Status != previousStatus {
+			refreshReplyCount(db, comment.ParentID)
+		}
+
+		// 待审核评论通过后才对外可见，此时通知评论者和作品作者（回复则通知被回复者）
+		if req.Action == "approve" && neverPublished {
+			notify.CommentPublished(db, &comment)
+		}
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Comment reviewed successfully",
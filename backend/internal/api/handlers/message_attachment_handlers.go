@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/realtime"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+var errInvalidAttachments = errors.New("invalid attachments")
+
+// 私信附件：图片和常见文档，不超过 10MB；保存在公开的 uploads 目录之外，只能经接口下载
+var messageAttachmentUpload = uploadRule{
+	dir:     "./private/message-attachments",
+	maxSize: 10 * 1024 * 1024,
+	// 按文件内容识别：docx/xlsx 等新版 Office 文档识别为 zip，旧版识别为 OLE 复合文档
+	types: []string{
+		"image/", "text/plain", "application/pdf", "application/zip", "application/x-ole-storage",
+	},
+	defaultExt: ".bin",
+}
+
+// visibleMessagesTo 过滤掉 uid 已"仅为自己删除"的消息
+func visibleMessagesTo(uid uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("NOT (from_user_id = ? AND deleted_by_sender) AND NOT (to_user_id = ? AND deleted_by_recipient)", uid, uid)
+	}
+}
+
+// redactRecalled 已撤回的消息不返回内容和附件
+func redactRecalled(msgs []repo.Message) {
+	for i := range msgs {
+		if msgs[i].RecalledAt != nil {
+			msgs[i].Content = ""
+			msgs[i].Attachments = nil
+		}
+	}
+}
+
+// 上传私信附件，返回的附件 ID 在发送消息时通过 attachment_ids 引用
+func UploadMessageAttachment(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "请选择要上传的文件"})
+		}
+		filename, contentType, err := saveUpload(c, file, uid, messageAttachmentUpload)
+		switch {
+		case errors.Is(err, errUploadType):
+			return c.Status(400).JSON(types.Response{Success: false, Error: "不支持的文件类型"})
+		case errors.Is(err, errUploadTooLarge):
+			return c.Status(400).JSON(types.Response{Success: false, Error: "文件大小不能超过10MB"})
+		case err != nil:
+			return c.Status(500).JSON(types.Response{Success: false, Error: "文件保存失败"})
+		}
+
+		name := filepath.Base(file.Filename)
+		if len([]rune(name)) > 255 {
+			name = truncateRunes(name, 255)
+		}
+		attachment := repo.MessageAttachment{
+			UploaderID:  uid,
+			Name:        name,
+			ContentType: contentType,
+			Size:        file.Size,
+			Path:        filepath.Join(messageAttachmentUpload.dir, filename),
+		}
+		if err := db.Create(&attachment).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "写入附件记录失败"})
+		}
+		return c.Status(201).JSON(types.Response{Success: true, Data: attachment})
+	}
+}
+
+// 下载私信附件：上传者和收件人可下载，消息撤回后收件人不可下载
+func DownloadMessageAttachment(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		id, err := parseUintParam(c, "id")
+		if err != nil {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid attachment id"})
+		}
+
+		var attachment repo.MessageAttachment
+		if err := db.First(&attachment, id).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "Attachment not found"})
+		}
+		if attachment.UploaderID != uid {
+			var msg repo.Message
+			if attachment.MessageID == nil ||
+				db.First(&msg, *attachment.MessageID).Error != nil ||
+				msg.ToUserID != uid || msg.RecalledAt != nil {
+				return c.Status(404).JSON(types.Response{Success: false, Error: "Attachment not found"})
+			}
+		}
+		return sendAttachment(c, &attachment)
+	}
+}
+
+// 可以内联显示的位图类型；SVG 等可能含脚本的类型一律作为下载
+var inlineAttachmentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+}
+
+// sendAttachment 输出附件文件；位图内联显示，其余作为下载
+func sendAttachment(c *fiber.Ctx, a *repo.MessageAttachment) error {
+	disposition := "attachment"
+	if inlineAttachmentTypes[a.ContentType] {
+		disposition = "inline"
+	}
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	c.Set(fiber.HeaderCacheControl, "private, max-age=3600")
+	c.Set("X-Content-Type-Options", "nosniff")
+	if err := c.SendFile(a.Path); err != nil {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Attachment not found"})
+	}
+	c.Set(fiber.HeaderContentType, a.ContentType)
+	return nil
+}
+
+// findOwnMessage 读取路由中的消息并确认当前用户是收发一方；返回 false 时已写入错误响应
+func findOwnMessage(c *fiber.Ctx, db *gorm.DB, uid uint) (*repo.Message, bool) {
+	id, err := parseUintParam(c, "mid")
+	if err != nil {
+		_ = c.Status(400).JSON(types.Response{Success: false, Error: "Invalid message id"})
+		return nil, false
+	}
+	var msg repo.Message
+	if err := db.Scopes(visibleMessagesTo(uid)).
+		Where("from_user_id = ? OR to_user_id = ?", uid, uid).
+		First(&msg, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = c.Status(404).JSON(types.Response{Success: false, Error: "Message not found"})
+			return nil, false
+		}
+		_ = c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch message"})
+		return nil, false
+	}
+	return &msg, true
+}
+
+// 撤回消息：只有发送者可以在发送后 MESSAGE_UNSEND_WINDOW 内撤回
+func UnsendMessage(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		msg, ok := findOwnMessage(c, db, uid)
+		if !ok {
+			return nil
+		}
+		if msg.FromUserID != uid {
+			return c.Status(403).JSON(types.Response{Success: false, Error: "Only the sender can unsend a message"})
+		}
+		if msg.RecalledAt != nil {
+			return c.JSON(types.Response{Success: true, Message: "Message already unsent"})
+		}
+		if time.Since(msg.CreatedAt) > viper.GetDuration("MESSAGE_UNSEND_WINDOW") {
+			return c.Status(403).JSON(types.Response{Success: false, Error: "Unsend window has passed"})
+		}
+
+		now := time.Now()
+		if err := db.Model(msg).Update("recalled_at", now).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to unsend message"})
+		}
+
+		event := fiber.Map{"id": msg.ID, "from_user_id": msg.FromUserID, "to_user_id": msg.ToUserID, "recalled_at": now}
+		realtime.Publish(db, msg.ToUserID, realtime.EventMessageRecalled, event, nil)
+		realtime.Publish(db, uid, realtime.EventMessageRecalled, event, nil)
+		return c.JSON(types.Response{Success: true, Message: "Message unsent"})
+	}
+}
+
+// 仅为自己删除消息，对方仍可看到
+func DeleteMessageForMe(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		msg, ok := findOwnMessage(c, db, uid)
+		if !ok {
+			return nil
+		}
+
+		column := "deleted_by_recipient"
+		if msg.FromUserID == uid {
+			column = "deleted_by_sender"
+		}
+		if err := db.Model(msg).Update(column, true).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to delete message"})
+		}
+		return c.JSON(types.Response{Success: true, Message: "Message deleted"})
+	}
+}
@@ -1,64 +1,177 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"maimang/backend/internal/notify"
+	"maimang/backend/internal/realtime"
 	"maimang/backend/internal/repo"
 	"maimang/backend/internal/types"
 )
 
 type sendMessageRequest struct {
-	Content string `json:"content"`
+	Content       string `json:"content"`
+	AttachmentIDs []uint `json:"attachment_ids"` // 先通过 /messages/attachments 上传
 }
 
-// 获取与当前用户有过对话的会话列表（对方用户）
+// 每条消息最多附件数
+const maxMessageAttachments = 9
+
+// 会话摘要：对方用户、最后一条消息和未读数
+// 会话对方的公开信息；字段名与 repo.User 一致，不带邮箱、密码等私密字段
+type conversationUser struct {
+	ID        uint
+	Name      string
+	AvatarURL string
+}
+
+type conversationSummary struct {
+	User        conversationUser   `json:"user"`
+	LastMessage conversationLatest `json:"last_message"`
+	UnreadCount int64              `json:"unread_count"`
+}
+
+type conversationLatest struct {
+	ID         uint      `json:"id"`
+	FromUserID uint      `json:"from_user_id"`
+	Snippet    string    `json:"snippet"`
+	Recalled   bool      `json:"recalled"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// 会话列表预览的最大字符数
+const conversationSnippetLength = 100
+
+// 获取会话列表：按最后一条消息倒序，cursor 为上一页返回的 next_cursor
 func ListConversations(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		uid := c.Locals("uid").(uint)
+		limit := c.QueryInt("limit", 20)
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		// 游标为上一页最后一个会话的最后消息 ID；消息 ID 递增，与时间顺序一致
+		var cursor uint64
+		if v := c.Query("cursor"); v != "" {
+			var err error
+			if cursor, err = parseUint(v); err != nil {
+				return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid cursor"})
+			}
+		}
 
-		// 查询最近的消息，聚合出会话对方用户ID
-		var msgs []repo.Message
-		if err := db.Where("from_user_id = ? OR to_user_id = ?", uid, uid).
-			Order("created_at DESC").
-			Limit(200).
-			Find(&msgs).Error; err != nil {
+		var rows []struct {
+			OtherID        uint
+			LastMessageID  uint
+			LastFromUserID uint
+			LastSnippet    string
+			LastRecalled   bool
+			LastMessageAt  time.Time
+			UnreadCount    int64
+		}
+		err := db.Raw(`
+			WITH latest AS (
+				SELECT DISTINCT ON (other_id) other_id, id, from_user_id, content, recalled_at, created_at
+				FROM (
+					SELECT CASE WHEN from_user_id = @uid THEN to_user_id ELSE from_user_id END AS other_id,
+						id, from_user_id, content, recalled_at, created_at
+					FROM messages
+					WHERE (from_user_id = @uid AND NOT deleted_by_sender)
+						OR (to_user_id = @uid AND NOT deleted_by_recipient)
+				) m
+				ORDER BY other_id, id DESC
+			), unread AS (
+				SELECT from_user_id AS other_id, COUNT(*) AS unread_count
+				FROM messages
+				WHERE to_user_id = @uid AND read_at IS NULL AND recalled_at IS NULL AND NOT deleted_by_recipient
+				GROUP BY from_user_id
+			)
+			SELECT latest.other_id, latest.id AS last_message_id, latest.from_user_id AS last_from_user_id,
+				CASE WHEN latest.recalled_at IS NULL THEN LEFT(latest.content, @snippet) ELSE '' END AS last_snippet,
+				latest.recalled_at IS NOT NULL AS last_recalled, latest.created_at AS last_message_at,
+				COALESCE(unread.unread_count, 0) AS unread_count
+			FROM latest LEFT JOIN unread ON unread.other_id = latest.other_id
+			WHERE (@cursor = 0 OR latest.id < @cursor)
+				-- 拉黑的用户不出现在会话列表中
+				AND latest.other_id NOT IN (SELECT blocked_id FROM user_blocks WHERE user_id = @uid)
+			ORDER BY latest.id DESC
+			LIMIT @limit`,
+			sql.Named("uid", uid),
+			sql.Named("snippet", conversationSnippetLength),
+			sql.Named("cursor", cursor),
+			sql.Named("limit", limit+1),
+		).Scan(&rows).Error
+		if err != nil {
 			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch conversations"})
 		}
 
-		// 聚合唯一的对方用户ID，保持顺序
-		seen := make(map[uint]bool)
-		var otherIDs []uint
-		for _, m := range msgs {
-			var other uint
-			if m.FromUserID == uid {
-				other = m.ToUserID
-			} else {
-				other = m.FromUserID
+		nextCursor := ""
+		if len(rows) > limit {
+			rows = rows[:limit]
+			nextCursor = strconv.FormatUint(uint64(rows[limit-1].LastMessageID), 10)
+		}
+
+		otherIDs := make([]uint, 0, len(rows))
+		for _, r := range rows {
+			otherIDs = append(otherIDs, r.OtherID)
+		}
+		users := make(map[uint]conversationUser, len(rows))
+		if len(otherIDs) > 0 {
+			var list []repo.User
+			if err := db.Select("id", "name", "avatar_url").Where("id IN ?", otherIDs).Find(&list).Error; err != nil {
+				return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch users"})
 			}
-			if !seen[other] {
-				seen[other] = true
-				otherIDs = append(otherIDs, other)
+			for _, u := range list {
+				users[u.ID] = conversationUser{ID: u.ID, Name: u.Name, AvatarURL: u.AvatarURL}
 			}
 		}
 
-		if len(otherIDs) == 0 {
-			return c.JSON(types.Response{Success: true, Data: []repo.User{}})
+		conversations := make([]conversationSummary, 0, len(rows))
+		for _, r := range rows {
+			user, ok := users[r.OtherID]
+			if !ok {
+				// 对方账号已删除
+				user = conversationUser{ID: r.OtherID}
+			}
+			conversations = append(conversations, conversationSummary{
+				User: user,
+				LastMessage: conversationLatest{
+					ID:         r.LastMessageID,
+					FromUserID: r.LastFromUserID,
+					Snippet:    r.LastSnippet,
+					Recalled:   r.LastRecalled,
+					CreatedAt:  r.LastMessageAt,
+				},
+				UnreadCount: r.UnreadCount,
+			})
 		}
 
-		var users []repo.User
-		if err := db.Where("id IN ?", otherIDs).Find(&users).Error; err != nil {
-			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch users"})
-		}
+		return c.JSON(types.CursorResponse{Success: true, Data: conversations, NextCursor: nextCursor})
+	}
+}
 
-		return c.JSON(types.Response{Success: true, Data: users})
+// 获取未读私信总数，用于导航栏角标
+func GetUnreadMessageCount(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		var count int64
+		if err := db.Model(&repo.Message{}).
+			Where("to_user_id = ? AND read_at IS NULL AND recalled_at IS NULL AND NOT deleted_by_recipient", uid).
+			Where("from_user_id NOT IN (?)", blockedBy(db, uid)).
+			Count(&count).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to count messages"})
+		}
+		return c.JSON(types.Response{Success: true, Data: fiber.Map{"unread": count}})
 	}
 }
 
-// 获取与某个用户的消息历史
+// 获取与某个用户的消息历史。默认返回最新一页；before=<消息ID> 向前加载更早的消息，
+// after=<消息ID> 加载之后的新消息。每页内按时间正序排列，next_cursor 为继续同方向加载的游标
 func ListMessagesWith(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		uid := c.Locals("uid").(uint)
@@ -66,15 +179,67 @@ func ListMessagesWith(db *gorm.DB) fiber.Handler {
 		if err != nil {
 			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid user id"})
 		}
+		limit := c.QueryInt("limit", 50)
+		if limit < 1 || limit > 100 {
+			limit = 50
+		}
+		var before, after uint64
+		if v := c.Query("before"); v != "" {
+			if before, err = parseUint(v); err != nil {
+				return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid before cursor"})
+			}
+		}
+		if v := c.Query("after"); v != "" {
+			if after, err = parseUint(v); err != nil {
+				return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid after cursor"})
+			}
+		}
+		if before > 0 && after > 0 {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "before and after cannot be used together"})
+		}
+
+		// 已拉黑对方时只显示自己发出的消息
+		tx := db.Where("(from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)", uid, otherID, otherID, uid).
+			Where("from_user_id NOT IN (?)", blockedBy(db, uid)).
+			Scopes(visibleMessagesTo(uid)).
+			Preload("Attachments")
+		if after > 0 {
+			tx = tx.Where("id > ?", after).Order("id ASC")
+		} else {
+			if before > 0 {
+				tx = tx.Where("id < ?", before)
+			}
+			tx = tx.Order("id DESC")
+		}
 
 		var msgs []repo.Message
-		if err := db.Where("(from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)", uid, otherID, otherID, uid).
-			Order("created_at ASC").
-			Limit(200).
-			Find(&msgs).Error; err != nil {
+		if err := tx.Limit(limit + 1).Find(&msgs).Error; err != nil {
 			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch messages"})
 		}
-		return c.JSON(types.Response{Success: true, Data: msgs})
+
+		more := len(msgs) > limit
+		if more {
+			msgs = msgs[:limit]
+		}
+		// 向前加载时按倒序查询，返回前翻转为正序
+		if after == 0 {
+			for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+				msgs[i], msgs[j] = msgs[j], msgs[i]
+			}
+		}
+
+		redactRecalled(msgs)
+
+		nextCursor := ""
+		if more {
+			edge := msgs[0].ID
+			if after > 0 {
+				edge = msgs[len(msgs)-1].ID
+			}
+			nextCursor = strconv.FormatUint(uint64(edge), 10)
+		}
+
+		return c.JSON(types.CursorResponse{Success: true, Data: msgs, NextCursor: nextCursor})
 	}
 }
 
@@ -87,18 +252,54 @@ func SendMessage(db *gorm.DB) fiber.Handler {
 			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid user id"})
 		}
 		var req sendMessageRequest
-		if err := c.BodyParser(&req); err != nil || req.Content == "" {
+		if err := c.BodyParser(&req); err != nil || (req.Content == "" && len(req.AttachmentIDs) == 0) {
 			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid content"})
 		}
+		if len(req.AttachmentIDs) > maxMessageAttachments {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Too many attachments"})
+		}
+		if otherID == uid {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Cannot message yourself"})
+		}
+		if status, msg := checkCanMessage(db, uid, otherID); status != 0 {
+			return c.Status(status).JSON(types.Response{Success: false, Error: msg})
+		}
 
 		msg := repo.Message{
 			FromUserID: uid,
 			ToUserID:   uint(otherID),
 			Content:    req.Content,
 		}
-		if err := db.Create(&msg).Error; err != nil {
+		// 附件必须是自己上传且尚未发送过的
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&msg).Error; err != nil {
+				return err
+			}
+			if len(req.AttachmentIDs) == 0 {
+				return nil
+			}
+			res := tx.Model(&repo.MessageAttachment{}).
+				Where("id IN ? AND uploader_id = ? AND message_id IS NULL", req.AttachmentIDs, uid).
+				Update("message_id", msg.ID)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected != int64(len(req.AttachmentIDs)) {
+				return errInvalidAttachments
+			}
+			return tx.Where("message_id = ?", msg.ID).Find(&msg.Attachments).Error
+		})
+		if errors.Is(err, errInvalidAttachments) {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid attachments"})
+		}
+		if err != nil {
 			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to send message"})
 		}
+		notify.MessageReceived(db, &msg)
+		// 推送给收件人，并同步到发送者的其他设备；内容过长时只推送引用
+		ref := fiber.Map{"ID": msg.ID, "FromUserID": msg.FromUserID, "ToUserID": msg.ToUserID, "CreatedAt": msg.CreatedAt, "Truncated": true}
+		realtime.Publish(db, msg.ToUserID, realtime.EventMessage, msg, ref)
+		realtime.Publish(db, uid, realtime.EventMessage, msg, ref)
 		return c.Status(201).JSON(types.Response{Success: true, Data: msg})
 	}
 }
@@ -112,15 +313,62 @@ func MarkMessagesRead(db *gorm.DB) fiber.Handler {
 			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid user id"})
 		}
 		now := time.Now()
-		if err := db.Model(&repo.Message{}).
+		res := db.Model(&repo.Message{}).
 			Where("to_user_id = ? AND from_user_id = ? AND read_at IS NULL", uid, otherID).
-			Updates(map[string]interface{}{"read_at": &now}).Error; err != nil {
+			Updates(map[string]interface{}{"read_at": &now})
+		if res.Error != nil {
 			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to mark read"})
 		}
+		notify.MessagesRead(db, uid, otherID)
+		// 已读回执推送给发信人
+		if res.RowsAffected > 0 {
+			realtime.Publish(db, otherID, realtime.EventMessageRead, fiber.Map{"reader_id": uid, "read_at": now}, nil)
+		}
 		return c.JSON(types.Response{Success: true})
 	}
 }
 
+// checkCanMessage 校验收件人存在、可用，双方无拉黑关系且收件人的私信权限允许；
+// 允许时返回 0，否则返回状态码和错误信息
+func checkCanMessage(db *gorm.DB, from, to uint) (int, string) {
+	var recipient repo.User
+	if err := db.Select("id", "status", "dm_policy").First(&recipient, to).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 404, "User not found"
+		}
+		return 500, "Failed to fetch user"
+	}
+	if recipient.Status != "active" {
+		return 403, "Recipient is not available"
+	}
+	blocked, err := isBlockedEither(db, from, to)
+	if err != nil {
+		return 500, "Failed to check block list"
+	}
+	if blocked {
+		return 403, "You cannot message this user"
+	}
+	switch recipient.DMPolicy {
+	case repo.DMNobody:
+		return 403, "This user does not accept messages"
+	case repo.DMFollowing:
+		following, err := isFollowing(db, to, from)
+		if err != nil {
+			return 500, "Failed to check following"
+		}
+		if !following {
+			return 403, "This user only accepts messages from people they follow"
+		}
+	}
+	return 0, ""
+}
+
+// currentUserID 获取当前登录用户ID（可选认证路由中可能为空）
+func currentUserID(c *fiber.Ctx) (uint, bool) {
+	uid, ok := c.Locals("uid").(uint)
+	return uid, ok
+}
+
 func parseUintParam(c *fiber.Ctx, name string) (uint, error) {
 	id, err := parseUint(c.Params(name))
 	return uint(id), err
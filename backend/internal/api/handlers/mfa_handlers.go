@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/rbac"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
+	"maimang/backend/internal/types"
+)
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "麦芒文学社"
+)
+
+type loginMFAReq struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// LoginMFA 两步登录第二步：校验验证码或恢复码后签发正式令牌
+func LoginMFA(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req loginMFAReq
+		if err := c.BodyParser(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
+		}
+		claims, err := auth.ParseMFAToken(req.MFAToken)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+
+		var user repo.User
+		if err := db.First(&user, claims.UserID).Error; err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+		if user.Status != "active" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "account disabled"})
+		}
+		if !user.TOTPEnabled {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+
+		// 验证码与密码共用失败计数，防止暴力猜测验证码
+		if loginThrottled(c, db, user.Email) {
+			return nil
+		}
+		ok, err := verifySecondFactor(db, &user, req.Code, req.RecoveryCode)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "verify failed"})
+		}
+		if !ok {
+			if _, err := auth.RecordLoginFailure(db, user.Email, c.IP()); err != nil {
+				log.Printf("Record login failure error: %v", err)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid code"})
+		}
+		if err := auth.RecordLoginSuccess(db, user.Email); err != nil {
+			log.Printf("Reset login throttle error: %v", err)
+		}
+
+		tokens, err := issueTokens(c, db, &user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "token error"})
+		}
+		return c.JSON(tokens)
+	}
+}
+
+// 获取两步验证状态
+func GetTwoFactorStatus(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var user repo.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "User not found"})
+		}
+
+		var remaining int64
+		db.Model(&repo.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&remaining)
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data: fiber.Map{
+				"enabled":                  user.TOTPEnabled,
+				"required":                 twoFactorRequired(db, &user),
+				"recovery_codes_remaining": remaining,
+			},
+		})
+	}
+}
+
+// 开始绑定两步验证：生成密钥和 otpauth 链接，启用前需用验证码确认
+func SetupTwoFactor(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var user repo.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "User not found"})
+		}
+		if user.TOTPEnabled {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Two-factor authentication already enabled"})
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to generate secret"})
+		}
+		if err := db.Model(&user).Update("totp_secret", secret).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to save secret"})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data: fiber.Map{
+				"secret":      secret,
+				"otpauth_uri": auth.TOTPURI(totpIssuer, user.Email, secret),
+			},
+		})
+	}
+}
+
+type twoFactorCodeReq struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	Password     string `json:"password"`
+}
+
+// 启用两步验证：校验首个验证码并返回恢复码（仅此一次可见）
+func EnableTwoFactor(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var req twoFactorCodeReq
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid request body"})
+		}
+
+		var user repo.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "User not found"})
+		}
+		if user.TOTPEnabled {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Two-factor authentication already enabled"})
+		}
+		if user.TOTPSecret == "" {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Call setup first"})
+		}
+
+		step, ok := auth.VerifyTOTP(user.TOTPSecret, req.Code, time.Now())
+		if !ok {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid code"})
+		}
+
+		var codes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"totp_enabled":   true,
+				"totp_last_step": step,
+			}).Error; err != nil {
+				return err
+			}
+			var err error
+			codes, err = replaceRecoveryCodes(tx, user.ID)
+			return err
+		})
+		auth.InvalidateUserState(user.ID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to enable two-factor authentication"})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Two-factor authentication enabled",
+			Data:    fiber.Map{"recovery_codes": codes},
+		})
+	}
+}
+
+// 关闭两步验证：需要密码和验证码（或恢复码）
+func DisableTwoFactor(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var req twoFactorCodeReq
+		if err := c.BodyParser(&req); err != nil || req.Password == "" || (req.Code == "" && req.RecoveryCode == "") {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid request body"})
+		}
+
+		var user repo.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "User not found"})
+		}
+		if !user.TOTPEnabled {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Two-factor authentication not enabled"})
+		}
+		if twoFactorRequired(db, &user) {
+			return c.Status(403).JSON(types.Response{Success: false, Error: "Two-factor authentication is required for your role"})
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Password is incorrect"})
+		}
+		ok, err := verifySecondFactor(db, &user, req.Code, req.RecoveryCode)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to verify code"})
+		}
+		if !ok {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid code"})
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"totp_enabled":   false,
+				"totp_secret":    "",
+				"totp_last_step": 0,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Where("user_id = ?", user.ID).Delete(&repo.RecoveryCode{}).Error
+		})
+		auth.InvalidateUserState(user.ID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to disable two-factor authentication"})
+		}
+
+		return c.JSON(types.Response{Success: true, Message: "Two-factor authentication disabled"})
+	}
+}
+
+// 重新生成恢复码：旧恢复码全部作废
+func RegenerateRecoveryCodes(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var req twoFactorCodeReq
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid request body"})
+		}
+
+		var user repo.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "User not found"})
+		}
+		if !user.TOTPEnabled {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Two-factor authentication not enabled"})
+		}
+		ok, err := verifySecondFactor(db, &user, req.Code, "")
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to verify code"})
+		}
+		if !ok {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid code"})
+		}
+
+		var codes []string
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			codes, err = replaceRecoveryCodes(tx, user.ID)
+			return err
+		}); err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to generate recovery codes"})
+		}
+
+		return c.JSON(types.Response{Success: true, Data: fiber.Map{"recovery_codes": codes}})
+	}
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码；验证码同一步长只能使用一次，恢复码使用后作废
+func verifySecondFactor(db *gorm.DB, user *repo.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := auth.VerifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		res := db.Model(&repo.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if res.Error != nil {
+			return false, res.Error
+		}
+		return res.RowsAffected == 1, nil
+	}
+	if recoveryCode != "" {
+		hash := auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode))
+		res := db.Model(&repo.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hash).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return false, res.Error
+		}
+		return res.RowsAffected == 1, nil
+	}
+	return false, errors.New("no second factor provided")
+}
+
+// replaceRecoveryCodes 删除旧恢复码并生成新的一组，返回明文
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&repo.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]repo.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, repo.RecoveryCode{UserID: userID, CodeHash: auth.HashToken(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// twoFactorRequired 系统是否要求该用户启用两步验证
+func twoFactorRequired(db *gorm.DB, user *repo.User) bool {
+	return isStaff(db, user.Role) && settings.Bool(db, settings.RequireAdmin2FA, false)
+}
+
+// isStaff 角色能否进入管理后台；查询失败时按否处理
+func isStaff(db *gorm.DB, role repo.Role) bool {
+	staff, err := rbac.IsStaff(db, role)
+	if err != nil {
+		log.Printf("Check staff role error: %v", err)
+	}
+	return staff
+}
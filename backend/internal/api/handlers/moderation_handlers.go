@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+// 举报快照最多包含的消息数
+const maxReportMessages = 50
+
+// 举报快照中的一条消息；保留撤回和已删除的消息原文
+type snapshotMessage struct {
+	ID                 uint                     `json:"id"`
+	FromUserID         uint                     `json:"from_user_id"`
+	ToUserID           uint                     `json:"to_user_id"`
+	Content            string                   `json:"content"`
+	CreatedAt          time.Time                `json:"created_at"`
+	ReadAt             *time.Time               `json:"read_at,omitempty"`
+	RecalledAt         *time.Time               `json:"recalled_at,omitempty"`
+	DeletedBySender    bool                     `json:"deleted_by_sender"`
+	DeletedByRecipient bool                     `json:"deleted_by_recipient"`
+	Attachments        []repo.MessageAttachment `json:"attachments"`
+}
+
+// 举报详情：快照以原始 JSON 返回
+type moderationCaseDetail struct {
+	repo.ModerationCase
+	Messages json.RawMessage `json:"messages"`
+}
+
+// 举报与某用户的会话：保存所选消息（或最近的消息）快照，供管理员处理
+func ReportConversation(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid := c.Locals("uid").(uint)
+		otherID, err := parseUintParam(c, "id")
+		if err != nil {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Invalid user id"})
+		}
+		var req types.ReportConversationRequest
+		if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Reason is required"})
+		}
+		if len([]rune(req.Reason)) > 1000 {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Reason is too long"})
+		}
+		if len(req.MessageIDs) > maxReportMessages {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Too many messages"})
+		}
+
+		// 举报快照不受撤回和"仅为自己删除"影响
+		tx := db.Preload("Attachments").
+			Where("(from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)", uid, otherID, otherID, uid)
+		if len(req.MessageIDs) > 0 {
+			tx = tx.Where("id IN ?", req.MessageIDs)
+		}
+		var msgs []repo.Message
+		if err := tx.Order("id DESC").Limit(maxReportMessages).Find(&msgs).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to fetch messages"})
+		}
+		if len(msgs) == 0 || (len(req.MessageIDs) > 0 && len(msgs) != len(req.MessageIDs)) {
+			return c.Status(400).JSON(types.Response{Success: false, Error: "Messages not found in this conversation"})
+		}
+
+		snapshot := make([]snapshotMessage, len(msgs))
+		for i, m := range msgs {
+			// 按时间正序保存
+			snapshot[len(msgs)-1-i] = snapshotMessage{
+				ID:                 m.ID,
+				FromUserID:         m.FromUserID,
+				ToUserID:           m.ToUserID,
+				Content:            m.Content,
+				CreatedAt:          m.CreatedAt,
+				ReadAt:             m.ReadAt,
+				RecalledAt:         m.RecalledAt,
+				DeletedBySender:    m.DeletedBySender,
+				DeletedByRecipient: m.DeletedByRecipient,
+				Attachments:        m.Attachments,
+			}
+		}
+		raw, err := json.Marshal(snapshot)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to create report"})
+		}
+
+		report := repo.ModerationCase{
+			Kind:           "conversation",
+			ReporterID:     uid,
+			ReportedUserID: otherID,
+			Reason:         req.Reason,
+			Snapshot:       raw,
+			Status:         repo.ModerationOpen,
+		}
+		if err := db.Create(&report).Error; err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Failed to create report"})
+		}
+		return c.Status(201).JSON(types.Response{Success: true, Message: "Report submitted", Data: fiber.Map{"id": report.ID}})
+	}
+}
+
+// 获取举报列表（管理员）
+func ListModerationCases(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var cases []repo.ModerationCase
+		var total int64
+
+		tx := db.Model(&repo.ModerationCase{}).Preload("Reporter").Preload("ReportedUser")
+		if query.Status != "" {
+			tx = tx.Where("status = ?", query.Status)
+		}
+		if query.Type != "" {
+			tx = tx.Where("kind = ?", query.Type)
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("created_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&cases)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch moderation cases",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    cases,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// findModerationCase 读取路由中的举报；返回 false 时已写入错误响应
+func findModerationCase(c *fiber.Ctx, db *gorm.DB) (*repo.ModerationCase, bool) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		_ = c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Invalid case ID",
+		})
+		return nil, false
+	}
+	var mc repo.ModerationCase
+	if err := db.Preload("Reporter").Preload("ReportedUser").Preload("Resolver").First(&mc, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "Moderation case not found",
+			})
+			return nil, false
+		}
+		_ = c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Failed to fetch moderation case",
+		})
+		return nil, false
+	}
+	return &mc, true
+}
+
+// 获取举报详情，包含消息快照（管理员）
+func GetModerationCase(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mc, ok := findModerationCase(c, db)
+		if !ok {
+			return nil
+		}
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    moderationCaseDetail{ModerationCase: *mc, Messages: mc.Snapshot},
+		})
+	}
+}
+
+// 处理举报：标记为已处理或驳回，也可重新打开（管理员）
+func UpdateModerationCase(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		adminID := c.Locals("uid").(uint)
+		mc, ok := findModerationCase(c, db)
+		if !ok {
+			return nil
+		}
+
+		var req types.UpdateModerationCaseRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+		status := repo.ModerationStatus(req.Status)
+		switch status {
+		case repo.ModerationOpen, repo.ModerationResolved, repo.ModerationDismissed:
+		default:
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "status must be one of open, resolved, dismissed",
+			})
+		}
+		if len([]rune(req.Note)) > 1000 {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Note is too long",
+			})
+		}
+
+		updates := map[string]interface{}{
+			"status":          status,
+			"resolution_note": req.Note,
+			"resolved_at":     nil,
+			"resolved_by":     nil,
+		}
+		if status != repo.ModerationOpen {
+			updates["resolved_at"] = time.Now()
+			updates["resolved_by"] = adminID
+		}
+		if err := db.Model(mc).Updates(updates).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to update moderation case",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Moderation case updated successfully",
+		})
+	}
+}
+
+// 下载举报会话中的附件（管理员）；附件须属于举报双方之间的消息
+func GetModerationAttachment(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mc, ok := findModerationCase(c, db)
+		if !ok {
+			return nil
+		}
+		aid, err := parseUintParam(c, "aid")
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid attachment ID",
+			})
+		}
+
+		var attachment repo.MessageAttachment
+		err = db.Joins("JOIN messages ON messages.id = message_attachments.message_id").
+			Where("(messages.from_user_id = ? AND messages.to_user_id = ?) OR (messages.from_user_id = ? AND messages.to_user_id = ?)",
+				mc.ReporterID, mc.ReportedUserID, mc.ReportedUserID, mc.ReporterID).
+			First(&attachment, "message_attachments.id = ?", aid).Error
+		if err != nil {
+			return c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "Attachment not found",
+			})
+		}
+		return sendAttachment(c, &attachment)
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/notify"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+// 获取我的通知：status=unread 只看未读，type 按通知类型筛选
+func GetNotifications(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var notifications []repo.Notification
+		var total int64
+
+		tx := db.Model(&repo.Notification{}).Where("user_id = ? AND silent = ?", userID, false)
+		if query.Status == "unread" {
+			tx = tx.Where("read_at IS NULL")
+		}
+		if query.Type != "" {
+			tx = tx.Where("type = ?", query.Type)
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Preload("Actor").
+			Order("created_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&notifications)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch notifications",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    notifications,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// 获取未读通知数
+func GetUnreadNotificationCount(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		count, err := notify.UnreadCount(db, userID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to count notifications",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    fiber.Map{"unread": count},
+		})
+	}
+}
+
+// 标记一条通知为已读
+func MarkNotificationRead(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		notificationID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid notification ID",
+			})
+		}
+
+		if err := notify.MarkRead(db, userID, uint(notificationID)); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Notification not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to mark notification read",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Notification marked as read",
+		})
+	}
+}
+
+// 全部标记为已读
+func MarkAllNotificationsRead(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		updated, err := notify.MarkAllRead(db, userID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to mark notifications read",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "All notifications marked as read",
+			Data:    fiber.Map{"updated": updated},
+		})
+	}
+}
+
+// 获取我的通知设置
+func GetNotificationSettings(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		settings, err := notify.LoadSettings(db, userID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch notification settings",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    settings,
+		})
+	}
+}
+
+// 更新我的通知设置：email_frequency 为 instant/daily/weekly，types 只需包含要修改的类型
+func UpdateNotificationSettings(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var req types.UpdateNotificationSettingsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+
+		prefs := make([]notify.Preference, 0, len(req.Types))
+		for _, t := range req.Types {
+			prefs = append(prefs, notify.Preference{
+				Type:  repo.NotificationType(t.Type),
+				InApp: t.InApp,
+				Email: t.Email,
+			})
+		}
+		if err := notify.SaveSettings(db, userID, req.EmailFrequency, prefs); err != nil {
+			switch err {
+			case notify.ErrUnknownFrequency:
+				return c.Status(400).JSON(types.Response{
+					Success: false,
+					Error:   "email_frequency must be one of instant, daily, weekly",
+				})
+			case notify.ErrUnknownType:
+				return c.Status(400).JSON(types.Response{
+					Success: false,
+					Error:   "Unknown notification type",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to update notification settings",
+			})
+		}
+
+		settings, err := notify.LoadSettings(db, userID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch notification settings",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Notification settings updated successfully",
+			Data:    settings,
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/oidc"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
+)
+
+// 回调后前端用一次性凭据换取令牌的有效期
+const oidcLoginCodeTTL = time.Minute
+
+var (
+	errOIDCEmailUnverified = errors.New("email_unverified")
+	errOIDCSignupDisabled  = errors.New("signup_disabled")
+	errOIDCEmailInvalid    = errors.New("email_invalid")
+	errOIDCLinkUnverified  = errors.New("link_unverified")
+)
+
+// ListOIDCProviders 列出可用的第三方登录方式
+func ListOIDCProviders() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list := []fiber.Map{}
+		for _, p := range oidc.List() {
+			list = append(list, fiber.Map{"name": p.Name, "display_name": p.DisplayName()})
+		}
+		return c.JSON(fiber.Map{"providers": list})
+	}
+}
+
+// OIDCStart 跳转到身份提供方登录页；redirect 为登录完成后前端要回到的站内路径
+func OIDCStart(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p, err := oidc.Lookup(c.Params("provider"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown provider"})
+		}
+		redirectTo := c.Query("redirect")
+		if !isLocalPath(redirectTo) {
+			redirectTo = ""
+		}
+
+		state, nonce, verifier, err := oidc.SaveState(db, p.Name, redirectTo)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "state error"})
+		}
+		target, err := p.AuthCodeURL(c.Context(), state, nonce, verifier)
+		if err != nil {
+			log.Printf("OIDC %s discovery error: %v", p.Name, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "identity provider unavailable"})
+		}
+		return c.Redirect(target, fiber.StatusFound)
+	}
+}
+
+// OIDCCallback 身份提供方回调：校验 state 与 ID Token，关联或创建用户，
+// 再带着一次性凭据跳回前端，由前端调用 /auth/oidc/exchange 换取令牌
+func OIDCCallback(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p, err := oidc.Lookup(c.Params("provider"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown provider"})
+		}
+		if e := c.Query("error"); e != "" {
+			return oidcRedirect(c, "", fiber.Map{"error": "provider_" + e})
+		}
+
+		st, err := oidc.ConsumeState(db, p.Name, c.Query("state"))
+		if err != nil {
+			return oidcRedirect(c, "", fiber.Map{"error": "invalid_state"})
+		}
+
+		identity, err := p.Exchange(c.Context(), c.Query("code"), st.CodeVerifier, st.Nonce)
+		if err != nil {
+			log.Printf("OIDC %s exchange error: %v", p.Name, err)
+			return oidcRedirect(c, st.RedirectTo, fiber.Map{"error": "exchange_failed"})
+		}
+
+		user, err := resolveOIDCUser(db, p, identity)
+		if err != nil {
+			code := "login_failed"
+			switch {
+			case errors.Is(err, errOIDCEmailUnverified):
+				code = "email_unverified"
+			case errors.Is(err, oidc.ErrEmailNotAllowed):
+				code = "email_not_allowed"
+			case errors.Is(err, errOIDCSignupDisabled):
+				code = "signup_disabled"
+			case errors.Is(err, errOIDCEmailInvalid):
+				code = "email_invalid"
+			case errors.Is(err, errOIDCLinkUnverified):
+				code = "account_email_unverified"
+			default:
+				log.Printf("OIDC %s resolve user error: %v", p.Name, err)
+			}
+			return oidcRedirect(c, st.RedirectTo, fiber.Map{"error": code})
+		}
+
+		code, err := auth.IssueOneTimeToken(db, user.ID, repo.TokenOIDCLogin, oidcLoginCodeTTL)
+		if err != nil {
+			return oidcRedirect(c, st.RedirectTo, fiber.Map{"error": "login_failed"})
+		}
+		return oidcRedirect(c, st.RedirectTo, fiber.Map{"code": code})
+	}
+}
+
+type oidcExchangeReq struct {
+	Code string `json:"code"`
+}
+
+// OIDCExchange 用回调得到的一次性凭据换取令牌，响应与 Login 相同（含两步验证）
+func OIDCExchange(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req oidcExchangeReq
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad payload"})
+		}
+		uid, err := auth.ConsumeOneTimeToken(db, req.Code, repo.TokenOIDCLogin)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid code"})
+		}
+		var user repo.User
+		if err := db.First(&user, uid).Error; err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid code"})
+		}
+		return completeLogin(c, db, &user)
+	}
+}
+
+// resolveOIDCUser 按 (provider, subject) 查找已绑定用户；未绑定时按已验证邮箱关联已有账号，
+// 仍找不到且允许注册时创建新账号。本站邮箱未验证的账号不自动关联：任何人都能用别人的邮箱注册，
+// 关联后注册者仍可用自己的密码登录该账号。用户可先通过找回密码证明邮箱归属，再用第三方登录
+func resolveOIDCUser(db *gorm.DB, p *oidc.Provider, id *oidc.Identity) (*repo.User, error) {
+	now := time.Now()
+	var user repo.User
+
+	var link repo.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", p.Name, id.Subject).First(&link).Error
+	if err == nil {
+		if err := db.First(&user, link.UserID).Error; err != nil {
+			return nil, err
+		}
+		db.Model(&link).Updates(map[string]interface{}{"last_login_at": now, "email": id.Email})
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if id.Email == "" || !id.EmailVerified {
+		return nil, errOIDCEmailUnverified
+	}
+	addr, err := mail.ParseAddress(id.Email)
+	if err != nil || addr.Address != id.Email {
+		return nil, errOIDCEmailInvalid
+	}
+	if !p.EmailAllowed(id.Email) {
+		return nil, oidc.ErrEmailNotAllowed
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("LOWER(email) = LOWER(?)", id.Email).First(&user).Error
+		switch {
+		case err == nil:
+			if user.EmailVerifiedAt == nil {
+				return errOIDCLinkUnverified
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// 邀请注册模式下无法校验邀请码，不自动创建账号
+			mode := settings.Registration(tx)
+			if !p.AllowSignup() || mode == settings.RegistrationInvite {
+				return errOIDCSignupDisabled
+			}
+			// 随机密码：用户如需密码登录可走找回密码流程设置
+			random, err := auth.RandomToken(32)
+			if err != nil {
+				return err
+			}
+			hashed, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSpace(id.Name)
+			if name == "" {
+				name = id.Email[:strings.Index(id.Email, "@")]
+			}
+			user = repo.User{
+				Name:            name,
+				Email:           id.Email,
+				Password:        string(hashed),
+				Role:            repo.RoleMember,
+				EmailVerifiedAt: &now,
+			}
+			if mode == settings.RegistrationApproval {
+				user.Status = "inactive"
+				user.PendingApproval = true
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		return tx.Create(&repo.UserIdentity{
+			UserID:      user.ID,
+			Provider:    p.Name,
+			Subject:     id.Subject,
+			Email:       id.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// oidcRedirect 跳回前端的第三方登录回调页
+func oidcRedirect(c *fiber.Ctx, redirectTo string, params fiber.Map) error {
+	q := url.Values{}
+	for k, v := range params {
+		q.Set(k, v.(string))
+	}
+	if redirectTo != "" {
+		q.Set("redirect", redirectTo)
+	}
+	return c.Redirect(viper.GetString("APP_BASE_URL")+"/auth/oidc/callback?"+q.Encode(), fiber.StatusFound)
+}
+
+// isLocalPath 只接受站内路径，防止开放重定向
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.HasPrefix(p, "/\\")
+}
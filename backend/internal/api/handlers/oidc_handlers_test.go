@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"maimang/backend/internal/oidc"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
+	"maimang/backend/internal/testdb"
+)
+
+func TestResolveOIDCUser(t *testing.T) {
+	if err := oidc.Configure(map[string]oidc.ProviderConfig{
+		"open":   {Issuer: "https://idp.test", ClientID: "c", AllowSignup: true},
+		"closed": {Issuer: "https://idp.test", ClientID: "c"},
+	}, "https://mm.test"); err != nil {
+		t.Fatal(err)
+	}
+	defer oidc.Configure(nil, "")
+	open, _ := oidc.Lookup("open")
+	closed, _ := oidc.Lookup("closed")
+
+	verified := time.Now()
+	tests := []struct {
+		name     string
+		provider *oidc.Provider
+		existing *repo.User
+		id       oidc.Identity
+		wantErr  error
+		wantNew  bool
+	}{
+		{
+			name:     "邮箱未经身份提供方验证",
+			provider: open,
+			id:       oidc.Identity{Subject: "s1", Email: "a@example.com"},
+			wantErr:  errOIDCEmailUnverified,
+		},
+		{
+			name:     "邮箱格式不对",
+			provider: open,
+			id:       oidc.Identity{Subject: "s1", Email: "not-an-email", EmailVerified: true},
+			wantErr:  errOIDCEmailInvalid,
+		},
+		{
+			name:     "带显示名的地址",
+			provider: open,
+			id:       oidc.Identity{Subject: "s1", Email: "Eve <a@example.com>", EmailVerified: true},
+			wantErr:  errOIDCEmailInvalid,
+		},
+		{
+			name:     "本站账号邮箱尚未验证时不自动关联",
+			provider: open,
+			existing: &repo.User{Name: "a", Email: "a@example.com", Status: "active"},
+			id:       oidc.Identity{Subject: "s1", Email: "a@example.com", EmailVerified: true},
+			wantErr:  errOIDCLinkUnverified,
+		},
+		{
+			name:     "关联邮箱大小写不同的已验证账号",
+			provider: closed,
+			existing: &repo.User{Name: "a", Email: "A@Example.com", Status: "active", EmailVerifiedAt: &verified},
+			id:       oidc.Identity{Subject: "s1", Email: "a@example.com", EmailVerified: true},
+		},
+		{
+			name:     "不允许自动注册",
+			provider: closed,
+			id:       oidc.Identity{Subject: "s1", Email: "new@example.com", EmailVerified: true},
+			wantErr:  errOIDCSignupDisabled,
+		},
+		{
+			name:     "自动注册新账号",
+			provider: open,
+			id:       oidc.Identity{Subject: "s1", Email: "new@example.com", EmailVerified: true},
+			wantNew:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t, &repo.User{}, &repo.UserIdentity{}, &repo.SystemSetting{})
+			settings.Invalidate()
+			if tt.existing != nil {
+				if err := db.Create(tt.existing).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			user, err := resolveOIDCUser(db, tt.provider, &tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			switch {
+			case tt.existing != nil && user.ID != tt.existing.ID:
+				t.Errorf("linked user %d, want %d", user.ID, tt.existing.ID)
+			case tt.wantNew && (user.Email != tt.id.Email || user.EmailVerifiedAt == nil || user.Role != repo.RoleMember):
+				t.Errorf("created user = %+v", user)
+			}
+
+			// 再次登录按 subject 找到同一账号，即使身份提供方上的邮箱已变更
+			again := tt.id
+			again.Email = "changed@example.com"
+			again.EmailVerified = false
+			got, err := resolveOIDCUser(db, tt.provider, &again)
+			if err != nil || got.ID != user.ID {
+				t.Errorf("second login = %v, %v, want user %d", got, err, user.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+// blockedBy 返回 uid 拉黑的用户 ID 子查询，用于 NOT IN 过滤
+func blockedBy(db *gorm.DB, uid uint) *gorm.DB {
+	return db.Model(&repo.UserBlock{}).Select("blocked_id").Where("user_id = ?", uid)
+}
+
+// isBlockedEither 判断两个用户之间是否存在任一方向的拉黑
+func isBlockedEither(db *gorm.DB, a, b uint) (bool, error) {
+	var count int64
+	err := db.Model(&repo.UserBlock{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// isFollowing 判断 uid 是否关注了 target
+func isFollowing(db *gorm.DB, uid, target uint) (bool, error) {
+	var count int64
+	err := db.Model(&repo.Follow{}).
+		Where("user_id = ? AND following_id = ?", uid, target).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// findTargetUser 读取请求体中的 user_id 并确认用户存在且不是自己；返回 false 时已写入错误响应
+func findTargetUser(c *fiber.Ctx, db *gorm.DB, userID uint) (*repo.User, bool) {
+	var req types.UserIDRequest
+	if err := c.BodyParser(&req); err != nil || req.UserID == 0 {
+		_ = c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+		return nil, false
+	}
+	if req.UserID == userID {
+		_ = c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Cannot target yourself",
+		})
+		return nil, false
+	}
+
+	var target repo.User
+	if err := db.First(&target, req.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			_ = c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "User not found",
+			})
+			return nil, false
+		}
+		_ = c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Failed to fetch user",
+		})
+		return nil, false
+	}
+	return &target, true
+}
+
+// 获取我的黑名单
+func ListBlocks(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var blocks []repo.UserBlock
+		if err := db.Where("user_id = ?", userID).
+			Preload("Blocked").
+			Order("created_at DESC").
+			Find(&blocks).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch blocks",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    blocks,
+		})
+	}
+}
+
+// 拉黑用户；同时解除双方的关注关系
+func BlockUser(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		target, ok := findTargetUser(c, db, userID)
+		if !ok {
+			return nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&repo.UserBlock{UserID: userID, BlockedID: target.ID}).Error; err != nil {
+				return err
+			}
+			return tx.Where("(user_id = ? AND following_id = ?) OR (user_id = ? AND following_id = ?)",
+				userID, target.ID, target.ID, userID).Delete(&repo.Follow{}).Error
+		})
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to block user",
+			})
+		}
+
+		return c.Status(201).JSON(types.Response{
+			Success: true,
+			Message: "User blocked successfully",
+		})
+	}
+}
+
+// 解除拉黑，:id 为被拉黑用户的 ID
+func UnblockUser(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		blockedID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid user ID",
+			})
+		}
+
+		res := db.Where("user_id = ? AND blocked_id = ?", userID, blockedID).Delete(&repo.UserBlock{})
+		if res.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to unblock user",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "Block not found",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "User unblocked successfully",
+		})
+	}
+}
+
+// 获取我关注的用户
+func ListFollowing(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var follows []repo.Follow
+		if err := db.Where("user_id = ?", userID).
+			Preload("Following").
+			Order("created_at DESC").
+			Find(&follows).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch following",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    follows,
+		})
+	}
+}
+
+// 关注用户；存在拉黑关系时不可关注
+func FollowUser(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		target, ok := findTargetUser(c, db, userID)
+		if !ok {
+			return nil
+		}
+
+		blocked, err := isBlockedEither(db, userID, target.ID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to follow user",
+			})
+		}
+		if blocked {
+			return c.Status(403).JSON(types.Response{
+				Success: false,
+				Error:   "Cannot follow this user",
+			})
+		}
+
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&repo.Follow{UserID: userID, FollowingID: target.ID}).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to follow user",
+			})
+		}
+
+		return c.Status(201).JSON(types.Response{
+			Success: true,
+			Message: "User followed successfully",
+		})
+	}
+}
+
+// 取消关注，:id 为被关注用户的 ID
+func UnfollowUser(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		followingID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid user ID",
+			})
+		}
+
+		res := db.Where("user_id = ? AND following_id = ?", userID, followingID).Delete(&repo.Follow{})
+		if res.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to unfollow user",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "Not following this user",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "User unfollowed successfully",
+		})
+	}
+}
+
+// 获取我的隐私设置
+func GetPrivacySettings(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var user repo.User
+		if err := db.Select("id", "dm_policy").First(&user, userID).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch privacy settings",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    fiber.Map{"dm_policy": user.DMPolicy},
+		})
+	}
+}
+
+// 更新隐私设置：dm_policy 为 everyone / following / nobody
+func UpdatePrivacySettings(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var req types.UpdatePrivacyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+		switch req.DMPolicy {
+		case repo.DMEveryone, repo.DMFollowing, repo.DMNobody:
+		default:
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "dm_policy must be one of everyone, following, nobody",
+			})
+		}
+
+		if err := db.Model(&repo.User{}).Where("id = ?", userID).
+			Update("dm_policy", req.DMPolicy).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to update privacy settings",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Privacy settings updated successfully",
+			Data:    fiber.Map{"dm_policy": req.DMPolicy},
+		})
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"maimang/backend/internal/auth"
 	"maimang/backend/internal/repo"
 	"maimang/backend/internal/types"
 )
@@ -64,6 +66,10 @@ func GetProfile(db *gorm.DB) fiber.Handler {
 			"status":     user.Status,
 			"created_at": user.CreatedAt,
 			"updated_at": user.UpdatedAt,
+
+			"email_verified_at": user.EmailVerifiedAt,
+			"totp_enabled":      user.TOTPEnabled,
+			"dm_policy":         user.DMPolicy,
 		}
 
 		log.Printf("Profile data: %+v", profileData)
@@ -210,6 +216,114 @@ func UpdateProfile(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// 修改密码：校验当前密码，成功后其他设备上的登录全部失效，并为当前设备签发新令牌
+func ChangePassword(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+
+		var req types.ChangePasswordRequest
+		if err := c.BodyParser(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+
+		var user repo.User
+		if err := db.First(&user, userID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "User not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch user",
+			})
+		}
+
+		// 校验当前密码与登录共用失败计数，防止用盗取的访问令牌反复猜测密码
+		if loginThrottled(c, db, user.Email) {
+			return nil
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+			if _, err := auth.RecordLoginFailure(db, user.Email, c.IP()); err != nil {
+				log.Printf("Record login failure error: %v", err)
+			}
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Current password is incorrect",
+			})
+		}
+		if err := auth.RecordLoginSuccess(db, user.Email); err != nil {
+			log.Printf("Record login success error: %v", err)
+		}
+		if req.NewPassword == req.CurrentPassword {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "New password must differ from the current one",
+			})
+		}
+		if err := auth.ValidatePassword(req.NewPassword, user.Email, user.Name); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to hash password",
+			})
+		}
+
+		// 更新密码、吊销全部刷新令牌和个人访问令牌并递增令牌版本
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"password":      string(hashed),
+				"token_version": gorm.Expr("token_version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+			if err := auth.RevokeUserRefreshTokens(tx, user.ID); err != nil {
+				return err
+			}
+			return auth.RevokeUserAPITokens(tx, user.ID)
+		})
+		auth.InvalidateUserState(user.ID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to change password",
+			})
+		}
+
+		// 重新读取令牌版本，为当前设备签发新令牌
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch user",
+			})
+		}
+		tokens, err := issueTokens(c, db, &user)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to issue tokens",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Password changed successfully",
+			Data:    tokens,
+		})
+	}
+}
+
 // 获取我的作品
 func GetMyWorks(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -282,6 +396,82 @@ func GetMyWorks(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// 获取我点赞过的作品
+func GetMyLikedWorks(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// 获取当前用户ID
+		userID := c.Locals("uid").(uint)
+
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var works []repo.Work
+		var total int64
+
+		// 构建查询 - 通过点赞表关联
+		tx := db.Model(&repo.Work{}).
+			Preload("Author").
+			Joins("JOIN work_likes ON works.id = work_likes.work_id").
+			Where("work_likes.user_id = ? AND works.status = ?", userID, repo.WorkApproved)
+
+		// 搜索条件
+		if query.Search != "" {
+			tx = tx.Where("works.title ILIKE ? OR works.content ILIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
+		}
+		if query.Type != "" {
+			tx = tx.Where("works.type = ?", query.Type)
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序（按点赞时间倒序）
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("work_likes.created_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&works)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch liked works",
+			})
+		}
+
+		for i := range works {
+			works[i].LikedByMe = true
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    works,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
 // 获取我的活动
 func GetMyActivities(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -353,46 +543,6 @@ func GetMyActivities(db *gorm.DB) fiber.Handler {
 	}
 }
 
-// 获取通知（模拟数据）
-func GetNotifications(db *gorm.DB) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		// 这里可以扩展为真正的通知系统
-		// 目前返回模拟数据
-		// userID := c.Locals("userID").(uint) // 将来用于获取用户特定通知
-		notifications := []map[string]interface{}{
-			{
-				"id":      1,
-				"type":    "work_approved",
-				"title":   "作品审核通过",
-				"content": "您的作品《秋日麦浪》已通过审核并发布",
-				"time":    "2小时前",
-				"read":    false,
-			},
-			{
-				"id":      2,
-				"type":    "activity_reminder",
-				"title":   "活动提醒",
-				"content": "您报名的\"秋日田野采风\"活动将于明天开始",
-				"time":    "1天前",
-				"read":    true,
-			},
-			{
-				"id":      3,
-				"type":    "comment",
-				"title":   "新评论",
-				"content": "有人评论了您的作品《秋日麦浪》",
-				"time":    "3天前",
-				"read":    true,
-			},
-		}
-
-		return c.JSON(types.Response{
-			Success: true,
-			Data:    notifications,
-		})
-	}
-}
-
 // 获取用户详情（管理员）
 func GetUser(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -423,7 +573,7 @@ func GetUser(db *gorm.DB) fiber.Handler {
 
 		return c.JSON(types.Response{
 			Success: true,
-			Data:    user,
+			Data:    newAdminUser(user),
 		})
 	}
 }
@@ -534,6 +684,10 @@ func DeleteUser(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		auth.InvalidateUserState(user.ID)
+		_ = auth.RevokeUserRefreshTokens(db, user.ID)
+		_ = auth.RevokeUserAPITokens(db, user.ID)
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "User deleted successfully",
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/realtime"
+)
+
+// 心跳间隔：保持代理不断开连接，并借此复查令牌是否被吊销
+const sseHeartbeat = 25 * time.Second
+
+// Events 实时事件流（SSE）：推送新私信、已读回执和新通知。
+// 浏览器 EventSource 无法设置请求头，可通过 ?access_token= 传递访问令牌；
+// 令牌过期或被吊销时服务端发送 expired 事件并断开，客户端刷新令牌后重连。
+func Events(db *gorm.DB, hub *realtime.Hub) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Query("access_token")
+		if header := c.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token = strings.TrimPrefix(header, "Bearer ")
+		}
+		if token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing token"})
+		}
+		if auth.IsAPIToken(token) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "personal access tokens are not supported"})
+		}
+		claims, err := auth.ParseToken(token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+		if _, err := auth.ValidateClaims(db, claims); err != nil {
+			switch {
+			case errors.Is(err, auth.ErrUserDisabled):
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "account disabled"})
+			case errors.Is(err, auth.ErrTokenRevoked):
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token revoked"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "auth check failed"})
+		}
+
+		sub := hub.Subscribe(claims.UserID)
+		if sub == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "server shutting down"})
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		expires := time.Now().Add(time.Hour)
+		if claims.ExpiresAt != nil {
+			expires = claims.ExpiresAt.Time
+		}
+
+		// 流写入在 handler 返回后执行，其中不能再使用 c
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer hub.Unsubscribe(sub)
+			heartbeat := time.NewTicker(sseHeartbeat)
+			defer heartbeat.Stop()
+			expiry := time.NewTimer(time.Until(expires))
+			defer expiry.Stop()
+
+			fmt.Fprint(w, "retry: 3000\nevent: ready\ndata: {}\n\n")
+			if err := w.Flush(); err != nil {
+				return
+			}
+			for {
+				select {
+				case e, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, e.Data)
+				case <-heartbeat.C:
+					if _, err := auth.ValidateClaims(db, claims); err != nil {
+						fmt.Fprint(w, "event: expired\ndata: {}\n\n")
+						_ = w.Flush()
+						return
+					}
+					fmt.Fprint(w, ": ping\n\n")
+				case <-expiry.C:
+					fmt.Fprint(w, "event: expired\ndata: {}\n\n")
+					_ = w.Flush()
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+		return nil
+	}
+}
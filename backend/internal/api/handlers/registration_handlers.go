@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
+	"maimang/backend/internal/types"
+)
+
+var errInviteInvalid = errors.New("invalid invite code")
+
+// GetRegistrationMode 公开当前注册模式，前端据此显示邀请码或申请说明输入框
+func GetRegistrationMode(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"mode": settings.Registration(db)})
+	}
+}
+
+// redeemInviteCode 原子地占用邀请码的一次使用次数，返回邀请码ID
+func redeemInviteCode(tx *gorm.DB, code string) (uint, error) {
+	var invite repo.InviteCode
+	res := tx.Model(&invite).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("code = ? AND revoked_at IS NULL", strings.ToUpper(code)).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("max_uses = 0 OR uses < max_uses").
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, errInviteInvalid
+	}
+	return invite.ID, nil
+}
+
+// 邀请码字符集：去掉易混淆的 0/O、1/I/L
+const inviteAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// generateInviteCode 生成形如 MM-XXXX-XXXX 的邀请码
+func generateInviteCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+	}
+	return "MM-" + string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// 获取邀请码列表（管理员）：status=active 只看仍可使用的邀请码
+func ListInviteCodes(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var codes []repo.InviteCode
+		var total int64
+
+		tx := db.Model(&repo.InviteCode{})
+		if query.Status == "active" {
+			tx = tx.Where("revoked_at IS NULL").
+				Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+				Where("max_uses = 0 OR uses < max_uses")
+		}
+		if query.Search != "" {
+			tx = tx.Where("code ILIKE ? OR note ILIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("created_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&codes)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch invite codes",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    codes,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// 批量生成邀请码（管理员）
+func CreateInviteCodes(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req types.CreateInviteCodesRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+		if req.Count == 0 {
+			req.Count = 1
+		}
+		if req.MaxUses == nil {
+			one := 1
+			req.MaxUses = &one
+		}
+		if req.Count < 1 || req.Count > 100 || *req.MaxUses < 0 || req.ExpiresInDays < 0 {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "count must be 1-100, max_uses and expires_in_days must not be negative",
+			})
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresInDays > 0 {
+			t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			expiresAt = &t
+		}
+
+		adminID := c.Locals("uid").(uint)
+		codes := make([]repo.InviteCode, 0, req.Count)
+		for i := 0; i < req.Count; i++ {
+			code, err := generateInviteCode()
+			if err != nil {
+				return c.Status(500).JSON(types.Response{
+					Success: false,
+					Error:   "Failed to generate invite code",
+				})
+			}
+			codes = append(codes, repo.InviteCode{
+				Code:      code,
+				Note:      truncateRunes(strings.TrimSpace(req.Note), 200),
+				MaxUses:   *req.MaxUses,
+				ExpiresAt: expiresAt,
+				CreatedBy: adminID,
+			})
+		}
+
+		if err := db.Create(&codes).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to create invite codes",
+			})
+		}
+
+		return c.Status(201).JSON(types.Response{
+			Success: true,
+			Data:    codes,
+			Message: "Invite codes created successfully",
+		})
+	}
+}
+
+// 作废邀请码（管理员）
+func RevokeInviteCode(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid invite code ID",
+			})
+		}
+
+		res := db.Model(&repo.InviteCode{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke invite code",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "Invite code not found or already revoked",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Invite code revoked successfully",
+		})
+	}
+}
+
+// 获取待审核的注册申请（管理员）
+func ListPendingUsers(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var users []repo.User
+		var total int64
+
+		tx := db.Model(&repo.User{}).Where("pending_approval = ?", true)
+		if query.Search != "" {
+			tx = tx.Where("name ILIKE ? OR email ILIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序：先申请的先处理
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Select("id", "created_at", "name", "email", "status", "pending_approval", "application_note", "email_verified_at").
+			Order("created_at ASC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&users)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch pending users",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    newAdminUsers(users),
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// 通过注册申请（管理员）：激活账号并邮件通知申请人
+func ApproveUser(db *gorm.DB, m mailer.Mailer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := findPendingUser(c, db)
+		if !ok {
+			return nil
+		}
+
+		if err := db.Model(user).Updates(map[string]interface{}{
+			"status":           "active",
+			"pending_approval": false,
+		}).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to approve user",
+			})
+		}
+		auth.InvalidateUserState(user.ID)
+
+		sendMailAsync(m, mailer.Message{
+			To:      []string{user.Email},
+			Subject: "麦芒文学社 - 入社申请已通过",
+			Body: "你好，" + user.Name + "：\n\n你的入社申请已通过审核，现在可以登录麦芒文学社了：\n\n" +
+				viper.GetString("APP_BASE_URL") + "/login\n",
+		})
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "User approved successfully",
+		})
+	}
+}
+
+// 拒绝注册申请（管理员）：邮件通知申请人并删除该账号，以便其日后重新申请
+func RejectUser(db *gorm.DB, m mailer.Mailer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := findPendingUser(c, db)
+		if !ok {
+			return nil
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		_ = c.BodyParser(&req)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", user.ID).Delete(&repo.UserToken{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.ID).Delete(&repo.UserIdentity{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.ID).Delete(&repo.RefreshToken{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(user).Error
+		})
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to reject user",
+			})
+		}
+		auth.InvalidateUserState(user.ID)
+
+		body := "你好，" + user.Name + "：\n\n很遗憾，你的入社申请未能通过审核。"
+		if reason := strings.TrimSpace(req.Reason); reason != "" {
+			body += "\n\n说明：" + reason
+		}
+		sendMailAsync(m, mailer.Message{
+			To:      []string{user.Email},
+			Subject: "麦芒文学社 - 入社申请结果",
+			Body:    body + "\n",
+		})
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "User rejected successfully",
+		})
+	}
+}
+
+// findPendingUser 按路径参数查找待审核用户，找不到时写入错误响应并返回 false
+func findPendingUser(c *fiber.Ctx, db *gorm.DB) (*repo.User, bool) {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		_ = c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+		return nil, false
+	}
+
+	var user repo.User
+	if err := db.Where("id = ? AND pending_approval = ?", userID, true).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			_ = c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "Pending user not found",
+			})
+			return nil, false
+		}
+		_ = c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Failed to fetch user",
+		})
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/rbac"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+type roleGrants struct {
+	Role        repo.Role `json:"role"`
+	Permissions []string  `json:"permissions"`
+	Editable    bool      `json:"editable"`
+}
+
+// 获取全部权限点（超级管理员）
+func ListPermissions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    rbac.All,
+		})
+	}
+}
+
+// 获取各角色的授权（超级管理员）
+func ListRolePermissions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		roles := append([]repo.Role{repo.RoleSuperAdmin}, rbac.Roles...)
+		result := make([]roleGrants, 0, len(roles))
+		for _, role := range roles {
+			perms, err := rbac.Permissions(db, role)
+			if err != nil {
+				return c.Status(500).JSON(types.Response{
+					Success: false,
+					Error:   "Failed to fetch role permissions",
+				})
+			}
+			result = append(result, roleGrants{
+				Role:        role,
+				Permissions: perms,
+				Editable:    rbac.IsConfigurable(role),
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    result,
+		})
+	}
+}
+
+// 替换角色的全部授权（超级管理员）；超级管理员角色不可修改
+func UpdateRolePermissions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role := repo.Role(c.Params("role"))
+		if !rbac.IsConfigurable(role) {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Role cannot be edited",
+			})
+		}
+
+		var req types.UpdateRolePermissionsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+
+		if err := rbac.SetPermissions(db, role, req.Permissions); err != nil {
+			if err == rbac.ErrUnknownPermission {
+				return c.Status(400).JSON(types.Response{
+					Success: false,
+					Error:   "Unknown permission",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to update role permissions",
+			})
+		}
+
+		perms, _ := rbac.Permissions(db, role)
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    roleGrants{Role: role, Permissions: perms, Editable: true},
+			Message: "Role permissions updated successfully",
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+// 获取登录失败计数与锁定列表（管理员）
+func ListLoginLockouts(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var rows []repo.LoginThrottle
+		var total int64
+
+		tx := db.Model(&repo.LoginThrottle{})
+
+		// status=locked 只看当前处于锁定中的记录
+		if query.Status == "locked" {
+			tx = tx.Where("locked_until > ?", time.Now())
+		}
+		if query.Search != "" {
+			tx = tx.Where("key ILIKE ?", "%"+query.Search+"%")
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("last_failure_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&rows)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch lockouts",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    rows,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// 解除登录锁定（管理员）
+func ClearLoginLockout(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid lockout ID",
+			})
+		}
+
+		adminID := c.Locals("uid").(uint)
+		if err := auth.ClearLockout(db, uint(id), adminID); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Lockout not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to clear lockout",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Lockout cleared successfully",
+		})
+	}
+}
+
+// 获取锁定审计记录（管理员）：search 按邮箱或 IP 模糊匹配
+func ListLockoutEvents(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var events []repo.LockoutEvent
+		var total int64
+
+		tx := db.Model(&repo.LockoutEvent{})
+		if query.Search != "" {
+			tx = tx.Where("email ILIKE ? OR ip ILIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("created_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&events)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch lockout events",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    events,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+// 获取我的登录会话，标记发起本次请求的会话
+func ListMySessions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		current, _ := c.Locals("sid").(string)
+
+		sessions, err := auth.ListSessions(db, userID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch sessions",
+			})
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    sessions,
+		})
+	}
+}
+
+// 结束我的某个登录会话，该设备需重新登录
+func RevokeMySession(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		if err := auth.RevokeSession(db, userID, c.Params("id")); err != nil {
+			if errors.Is(err, auth.ErrSessionNotFound) {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Session not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke session",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Session revoked successfully",
+		})
+	}
+}
+
+// 获取用户的登录会话（管理员）
+func ListUserSessions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := findSessionOwner(c, db)
+		if !ok {
+			return nil
+		}
+
+		sessions, err := auth.ListSessions(db, user.ID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch sessions",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    sessions,
+		})
+	}
+}
+
+// 结束用户的某个登录会话（管理员）
+func RevokeUserSession(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := findSessionOwner(c, db)
+		if !ok {
+			return nil
+		}
+
+		if err := auth.RevokeSession(db, user.ID, c.Params("sid")); err != nil {
+			if errors.Is(err, auth.ErrSessionNotFound) {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Session not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke session",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Session revoked successfully",
+		})
+	}
+}
+
+// 结束用户的全部登录会话（管理员），已签发的访问令牌一并失效
+func RevokeUserSessions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := findSessionOwner(c, db)
+		if !ok {
+			return nil
+		}
+
+		if err := auth.RevokeUserRefreshTokens(db, user.ID); err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke sessions",
+			})
+		}
+		if err := auth.BumpTokenVersion(db, user.ID); err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke sessions",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "All sessions revoked successfully",
+		})
+	}
+}
+
+// findSessionOwner 读取路由中的用户；只有超级管理员可以管理超级管理员的会话。
+// 返回 false 时已写入错误响应
+func findSessionOwner(c *fiber.Ctx, db *gorm.DB) (*repo.User, bool) {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		_ = c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+		return nil, false
+	}
+
+	var user repo.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			_ = c.Status(404).JSON(types.Response{
+				Success: false,
+				Error:   "User not found",
+			})
+			return nil, false
+		}
+		_ = c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Failed to fetch user",
+		})
+		return nil, false
+	}
+
+	role, _ := c.Locals("role").(string)
+	if user.Role == repo.RoleSuperAdmin && repo.Role(role) != repo.RoleSuperAdmin {
+		_ = c.Status(403).JSON(types.Response{
+			Success: false,
+			Error:   "Cannot manage sessions of a super admin",
+		})
+		return nil, false
+	}
+	return &user, true
+}
@@ -11,6 +11,7 @@ import (
 	"gorm.io/gorm/clause"
 
 	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
 	"maimang/backend/internal/types"
 )
 
@@ -38,6 +39,31 @@ func GetSystemSettings(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// 获取公开的系统设置（用于首页），不含仅管理员可见的设置
+func GetPublicSystemSettings(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var list []repo.SystemSetting
+		if err := db.Find(&list).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch system settings",
+			})
+		}
+
+		settingsMap := make(map[string]interface{})
+		for _, setting := range list {
+			if !settings.IsPrivate(setting.Key) {
+				settingsMap[setting.Key] = setting.Value
+			}
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    settingsMap,
+		})
+	}
+}
+
 // 更新系统设置
 func UpdateSystemSettings(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -116,6 +142,7 @@ func UpdateSystemSettings(db *gorm.DB) fiber.Handler {
 				Error:   "Failed to save settings",
 			})
 		}
+		settings.Invalidate()
 
 		return c.JSON(types.Response{
 			Success: true,
@@ -186,6 +213,7 @@ func UpdateSystemSetting(db *gorm.DB) fiber.Handler {
 				Error:   "Failed to update setting",
 			})
 		}
+		settings.Invalidate()
 
 		return c.JSON(types.Response{
 			Success: true,
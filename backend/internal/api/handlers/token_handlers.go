@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/auth"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/types"
+)
+
+const (
+	apiTokenDefaultDays = 90
+	apiTokenMaxDays     = 365
+	apiTokenMaxPerUser  = 20
+)
+
+// 获取我的个人访问令牌列表，同时返回当前角色可申请的权限范围
+func ListMyAPITokens(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		role, _ := c.Locals("role").(string)
+
+		var tokens []repo.APIToken
+		if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch tokens",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data: fiber.Map{
+				"tokens":           tokens,
+				"available_scopes": auth.AvailableScopes(isStaff(db, repo.Role(role))),
+			},
+		})
+	}
+}
+
+// 创建个人访问令牌；明文令牌只在本次响应中返回
+func CreateAPIToken(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		role, _ := c.Locals("role").(string)
+
+		var req types.CreateAPITokenRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len([]rune(req.Name)) > 100 {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Name is required and must be at most 100 characters",
+			})
+		}
+		scopes, err := auth.NormalizeScopes(isStaff(db, repo.Role(role)), req.Scopes)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid scopes",
+			})
+		}
+		if req.ExpiresInDays == 0 {
+			req.ExpiresInDays = apiTokenDefaultDays
+		}
+		if req.ExpiresInDays < 1 || req.ExpiresInDays > apiTokenMaxDays {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "expires_in_days must be between 1 and 365",
+			})
+		}
+
+		var active int64
+		db.Model(&repo.APIToken{}).
+			Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+			Count(&active)
+		if active >= apiTokenMaxPerUser {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Too many active tokens",
+			})
+		}
+
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		raw, token, err := auth.IssueAPIToken(db, userID, req.Name, scopes, &expiresAt)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to create token",
+			})
+		}
+
+		return c.Status(201).JSON(types.Response{
+			Success: true,
+			Data: fiber.Map{
+				"token":     raw,
+				"api_token": token,
+			},
+			Message: "Token created, copy it now as it will not be shown again",
+		})
+	}
+}
+
+// 重命名个人访问令牌
+func UpdateAPIToken(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		tokenID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid token ID",
+			})
+		}
+
+		var req types.UpdateAPITokenRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len([]rune(req.Name)) > 100 {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Name is required and must be at most 100 characters",
+			})
+		}
+
+		var token repo.APIToken
+		if err := db.Where("id = ? AND user_id = ?", tokenID, userID).First(&token).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Token not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch token",
+			})
+		}
+
+		if err := db.Model(&token).Update("name", req.Name).Error; err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to update token",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Data:    token,
+			Message: "Token updated successfully",
+		})
+	}
+}
+
+// 吊销我的个人访问令牌
+func RevokeMyAPIToken(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("uid").(uint)
+		tokenID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid token ID",
+			})
+		}
+
+		var token repo.APIToken
+		if err := db.Where("id = ? AND user_id = ?", tokenID, userID).First(&token).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Token not found",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch token",
+			})
+		}
+
+		if err := auth.RevokeAPIToken(db, token.ID, nil); err != nil && err != gorm.ErrRecordNotFound {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke token",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Token revoked successfully",
+		})
+	}
+}
+
+// 获取全部个人访问令牌（管理员）：user_id 按用户筛选，status=active 只看有效令牌
+func ListAPITokens(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query types.ListQuery
+		if err := c.QueryParser(&query); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid query parameters",
+			})
+		}
+
+		// 设置默认值
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		if query.PerPage == 0 {
+			query.PerPage = 20
+		}
+
+		var tokens []repo.APIToken
+		var total int64
+
+		tx := db.Model(&repo.APIToken{})
+		if uid := c.QueryInt("user_id"); uid > 0 {
+			tx = tx.Where("user_id = ?", uid)
+		}
+		if query.Status == "active" {
+			tx = tx.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+		}
+		if query.Search != "" {
+			tx = tx.Where("name ILIKE ? OR prefix ILIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
+		}
+
+		// 获取总数
+		tx.Count(&total)
+
+		// 分页和排序
+		offset := (query.Page - 1) * query.PerPage
+		tx = tx.Order("created_at DESC").
+			Offset(offset).
+			Limit(query.PerPage).
+			Find(&tokens)
+
+		if tx.Error != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to fetch tokens",
+			})
+		}
+
+		// 计算总页数
+		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
+
+		return c.JSON(types.PaginatedResponse{
+			Success: true,
+			Data:    tokens,
+			Meta: types.PaginationMeta{
+				Page:       query.Page,
+				PerPage:    query.PerPage,
+				Total:      total,
+				TotalPages: totalPages,
+			},
+		})
+	}
+}
+
+// 吊销任意个人访问令牌（管理员）
+func RevokeAPIToken(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Invalid token ID",
+			})
+		}
+
+		adminID := c.Locals("uid").(uint)
+		if err := auth.RevokeAPIToken(db, uint(tokenID), &adminID); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(404).JSON(types.Response{
+					Success: false,
+					Error:   "Token not found or already revoked",
+				})
+			}
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Failed to revoke token",
+			})
+		}
+
+		return c.JSON(types.Response{
+			Success: true,
+			Message: "Token revoked successfully",
+		})
+	}
+}
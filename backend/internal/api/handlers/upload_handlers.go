@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +21,97 @@ import (
 	"maimang/backend/internal/types"
 )
 
+// uploadRule 上传文件的校验规则和保存位置
+type uploadRule struct {
+	dir        string   // 保存目录
+	maxSize    int64    // 最大字节数
+	types      []string // 允许的文件类型前缀（按文件内容识别），为空不限制
+	defaultExt string   // 识别出的类型没有对应扩展名时使用
+}
+
+// 识别出的文件类型对应的扩展名
+var uploadExts = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"image/x-icon":    ".ico",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// OLE 复合文档（旧版 doc/xls/ppt）的文件头，http.DetectContentType 不识别
+var oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// detectContentType 按文件开头的内容识别类型，不采信客户端声明的 Content-Type
+func detectContentType(head []byte) string {
+	if bytes.HasPrefix(head, oleMagic) {
+		return "application/x-ole-storage"
+	}
+	return http.DetectContentType(head)
+}
+
+var (
+	errUploadType     = errors.New("file type not allowed")
+	errUploadTooLarge = errors.New("file too large")
+)
+
+// 头像：图片，不超过 2MB，公开访问
+var avatarUpload = uploadRule{
+	dir:        "./uploads/avatars",
+	maxSize:    2 * 1024 * 1024,
+	types:      []string{"image/"},
+	defaultExt: ".jpg",
+}
+
+// saveUpload 按文件内容识别类型并校验，以 "<用户ID>_<uuid><扩展名>" 保存到 rule.dir；
+// 扩展名由识别出的类型决定，返回文件名和识别出的类型
+func saveUpload(c *fiber.Ctx, file *multipart.FileHeader, userID uint, rule uploadRule) (string, string, error) {
+	if file.Size > rule.maxSize {
+		return "", "", errUploadTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return "", "", err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", "", err
+	}
+	contentType := detectContentType(head[:n])
+	if len(rule.types) > 0 {
+		allowed := false
+		for _, t := range rule.types {
+			if strings.HasPrefix(contentType, t) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", "", errUploadType
+		}
+	}
+
+	if err := os.MkdirAll(rule.dir, 0755); err != nil {
+		return "", "", fmt.Errorf("create upload directory: %w", err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	ext, ok := uploadExts[mediaType]
+	if !ok {
+		ext = rule.defaultExt
+	}
+	filename := fmt.Sprintf("%d_%s%s", userID, uuid.New().String(), ext)
+	if err := c.SaveFile(file, filepath.Join(rule.dir, filename)); err != nil {
+		return "", "", err
+	}
+	return filename, contentType, nil
+}
+
 // 上传头像
 func UploadAvatar() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -34,44 +131,21 @@ func UploadAvatar() fiber.Handler {
 			})
 		}
 
-		// 验证文件类型
-		if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
+		filename, _, err := saveUpload(c, file, userID, avatarUpload)
+		switch {
+		case errors.Is(err, errUploadType):
 			log.Printf("Invalid file type: %s", file.Header.Get("Content-Type"))
 			return c.Status(400).JSON(fiber.Map{
 				"success": false,
 				"error":   "只支持图片文件",
 			})
-		}
-
-		// 验证文件大小 (2MB)
-		if file.Size > 2*1024*1024 {
+		case errors.Is(err, errUploadTooLarge):
 			log.Printf("File too large: %d bytes", file.Size)
 			return c.Status(400).JSON(fiber.Map{
 				"success": false,
 				"error":   "文件大小不能超过2MB",
 			})
-		}
-
-		// 创建上传目录
-		uploadDir := "./uploads/avatars"
-		if err := os.MkdirAll(uploadDir, 0755); err != nil {
-			log.Printf("Failed to create upload directory: %v", err)
-			return c.Status(500).JSON(fiber.Map{
-				"success": false,
-				"error":   "服务器错误",
-			})
-		}
-
-		// 生成唯一文件名
-		ext := filepath.Ext(file.Filename)
-		if ext == "" {
-			ext = ".jpg" // 默认扩展名
-		}
-		filename := fmt.Sprintf("%d_%s%s", userID, uuid.New().String(), ext)
-		filepath := filepath.Join(uploadDir, filename)
-
-		// 保存文件
-		if err := c.SaveFile(file, filepath); err != nil {
+		case err != nil:
 			log.Printf("Failed to save file: %v", err)
 			return c.Status(500).JSON(fiber.Map{
 				"success": false,
@@ -5,8 +5,12 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"maimang/backend/internal/mention"
+	"maimang/backend/internal/notify"
 	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
 	"maimang/backend/internal/types"
 )
 
@@ -39,7 +43,7 @@ func ListWorks(db *gorm.DB) fiber.Handler {
 		var total int64
 
 		// 构建查询
-		tx := db.Model(&repo.Work{}).Preload("Author")
+		tx := db.Model(&repo.Work{}).Preload("Author").Preload("Mentions")
 
 		// 搜索条件
 		if query.Search != "" {
@@ -69,6 +73,11 @@ func ListWorks(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// 标记当前用户已点赞的作品
+		if uid, ok := currentUserID(c); ok {
+			markLikedWorks(db, uid, works)
+		}
+
 		// 计算总页数
 		totalPages := int((total + int64(query.PerPage) - 1) / int64(query.PerPage))
 
@@ -97,7 +106,7 @@ func GetWork(db *gorm.DB) fiber.Handler {
 		}
 
 		var work repo.Work
-		if err := db.Preload("Author").Preload("Reviewer").First(&work, workID).Error; err != nil {
+		if err := db.Preload("Author").Preload("Reviewer").Preload("Mentions").First(&work, workID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return c.Status(404).JSON(types.Response{
 					Success: false,
@@ -113,6 +122,12 @@ func GetWork(db *gorm.DB) fiber.Handler {
 		// 增加浏览量
 		db.Model(&work).Update("views", work.Views+1)
 
+		if uid, ok := currentUserID(c); ok {
+			var count int64
+			db.Model(&repo.WorkLike{}).Where("work_id = ? AND user_id = ?", work.ID, uid).Count(&count)
+			work.LikedByMe = count > 0
+		}
+
 		return c.JSON(types.Response{
 			Success: true,
 			Data:    work,
@@ -134,6 +149,23 @@ func CreateWork(db *gorm.DB) fiber.Handler {
 		// 获取当前用户ID（从JWT token中）
 		userID := c.Locals("uid").(uint)
 
+		// 系统要求验证邮箱后才能投稿
+		if settings.Bool(db, settings.RequireEmailVerification, false) {
+			var author repo.User
+			if err := db.Select("id", "email_verified_at").First(&author, userID).Error; err != nil {
+				return c.Status(500).JSON(types.Response{
+					Success: false,
+					Error:   "Failed to fetch user",
+				})
+			}
+			if author.EmailVerifiedAt == nil {
+				return c.Status(403).JSON(types.Response{
+					Success: false,
+					Error:   "Email verification required",
+				})
+			}
+		}
+
 		work := repo.Work{
 			Title:    req.Title,
 			Type:     repo.WorkType(req.Type),
@@ -148,9 +180,10 @@ func CreateWork(db *gorm.DB) fiber.Handler {
 				Error:   "Failed to create work",
 			})
 		}
+		mention.Sync(db, mention.TargetWork, work.ID, userID, work.Content)
 
 		// 预加载作者信息
-		db.Preload("Author").First(&work, work.ID)
+		db.Preload("Author").Preload("Mentions").First(&work, work.ID)
 
 		return c.Status(201).JSON(types.Response{
 			Success: true,
@@ -224,8 +257,16 @@ func UpdateWork(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// 重新解析提及；已发布的作品立即通知新提到的人
+		if req.Content != "" {
+			mention.Sync(db, mention.TargetWork, work.ID, userID, req.Content)
+			if work.Status == repo.WorkApproved {
+				notify.WorkMentions(db, &work)
+			}
+		}
+
 		// 预加载作者信息
-		db.Preload("Author").First(&work, work.ID)
+		db.Preload("Author").Preload("Mentions").First(&work, work.ID)
 
 		return c.JSON(types.Response{
 			Success: true,
@@ -272,8 +313,13 @@ func DeleteWork(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// 删除作品
-		if err := db.Delete(&work).Error; err != nil {
+		// 删除作品及其点赞记录
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("work_id = ?", work.ID).Delete(&repo.WorkLike{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&work).Error
+		}); err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
 				Error:   "Failed to delete work",
@@ -287,7 +333,7 @@ func DeleteWork(db *gorm.DB) fiber.Handler {
 	}
 }
 
-// 点赞作品
+// 点赞作品（幂等：重复点赞不会重复计数）
 func LikeWork(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		workID, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -298,23 +344,38 @@ func LikeWork(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// 检查作品是否存在
+		userID := c.Locals("uid").(uint)
+
 		var work repo.Work
-		if err := db.First(&work, workID).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// 未通过审核的作品不可点赞，按不存在处理
+			if err := tx.Where("status = ?", repo.WorkApproved).First(&work, workID).Error; err != nil {
+				return err
+			}
+
+			// 唯一索引保证同一用户只记录一次点赞
+			like := repo.WorkLike{WorkID: work.ID, UserID: userID}
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&like)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+
+			// 在数据库中原子递增，避免并发覆盖
+			if err := tx.Model(&work).UpdateColumn("likes", gorm.Expr("likes + 1")).Error; err != nil {
+				return err
+			}
+			return tx.Select("likes").First(&work, work.ID).Error
+		})
+		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return c.Status(404).JSON(types.Response{
 					Success: false,
 					Error:   "Work not found",
 				})
 			}
-			return c.Status(500).JSON(types.Response{
-				Success: false,
-				Error:   "Failed to fetch work",
-			})
-		}
-
-		// 增加点赞数
-		if err := db.Model(&work).Update("likes", work.Likes+1).Error; err != nil {
 			return c.Status(500).JSON(types.Response{
 				Success: false,
 				Error:   "Failed to like work",
@@ -324,11 +385,15 @@ func LikeWork(db *gorm.DB) fiber.Handler {
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Work liked successfully",
+			Data: fiber.Map{
+				"liked": true,
+				"likes": work.Likes,
+			},
 		})
 	}
 }
 
-// 取消点赞作品
+// 取消点赞作品（幂等：未点赞时不会改变计数）
 func UnlikeWork(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		workID, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -339,9 +404,28 @@ func UnlikeWork(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// 检查作品是否存在
+		userID := c.Locals("uid").(uint)
+
 		var work repo.Work
-		if err := db.First(&work, workID).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&work, workID).Error; err != nil {
+				return err
+			}
+
+			res := tx.Where("work_id = ? AND user_id = ?", work.ID, userID).Delete(&repo.WorkLike{})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return nil
+			}
+
+			if err := tx.Model(&work).UpdateColumn("likes", gorm.Expr("GREATEST(likes - 1, 0)")).Error; err != nil {
+				return err
+			}
+			return tx.Select("likes").First(&work, work.ID).Error
+		})
+		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return c.Status(404).JSON(types.Response{
 					Success: false,
@@ -350,27 +434,46 @@ func UnlikeWork(db *gorm.DB) fiber.Handler {
 			}
 			return c.Status(500).JSON(types.Response{
 				Success: false,
-				Error:   "Failed to fetch work",
+				Error:   "Failed to unlike work",
 			})
 		}
 
-		// 减少点赞数
-		if work.Likes > 0 {
-			if err := db.Model(&work).Update("likes", work.Likes-1).Error; err != nil {
-				return c.Status(500).JSON(types.Response{
-					Success: false,
-					Error:   "Failed to unlike work",
-				})
-			}
-		}
-
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Work unliked successfully",
+			Data: fiber.Map{
+				"liked": false,
+				"likes": work.Likes,
+			},
 		})
 	}
 }
 
+// markLikedWorks 为作品列表填充当前用户的点赞状态
+func markLikedWorks(db *gorm.DB, userID uint, works []repo.Work) {
+	if len(works) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(works))
+	for _, w := range works {
+		ids = append(ids, w.ID)
+	}
+
+	var likedIDs []uint
+	if err := db.Model(&repo.WorkLike{}).
+		Where("user_id = ? AND work_id IN ?", userID, ids).
+		Pluck("work_id", &likedIDs).Error; err != nil {
+		return
+	}
+	liked := make(map[uint]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range works {
+		works[i].LikedByMe = liked[works[i].ID]
+	}
+}
+
 // 获取待审核作品列表（管理员）
 func ListPendingWorks(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -495,6 +598,13 @@ func ReviewWork(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		switch req.Action {
+		case "approve":
+			notify.WorkReviewed(db, &work, true, req.Note)
+		case "reject":
+			notify.WorkReviewed(db, &work, false, req.Note)
+		}
+
 		return c.JSON(types.Response{
 			Success: true,
 			Message: "Work reviewed successfully",
@@ -1,27 +1,124 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/spf13/viper"
+	"gorm.io/gorm"
 
 	"maimang/backend/internal/auth"
 )
 
-func AuthRequired() fiber.Handler {
+// AuthRequired 校验访问令牌，并以数据库中的实时状态确认用户未被封禁、令牌未被吊销。
+// 写入 Locals 的 role 取自数据库而非令牌，角色变更立即生效。
+// 令牌所属登录会话被结束后立即失效。
+// 同时接受个人访问令牌（mm_pat_ 前缀），按路由校验其权限范围。
+func AuthRequired(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		header := c.Get("Authorization")
 		if !strings.HasPrefix(header, "Bearer ") {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing token"})
 		}
 		token := strings.TrimPrefix(header, "Bearer ")
-		claims, err := auth.ParseToken(viper.GetString("JWT_SECRET"), token)
+		if auth.IsAPIToken(token) {
+			return apiTokenAuth(c, db, token)
+		}
+		claims, err := auth.ParseToken(token)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
 		}
+		state, err := auth.ValidateClaims(db, claims)
+		if err != nil {
+			return authError(c, err)
+		}
 		c.Locals("uid", claims.UserID)
-		c.Locals("role", claims.Role)
+		c.Locals("role", state.Role)
+		c.Locals("mfa_enabled", state.TOTPEnabled)
+		if claims.SessionID != "" {
+			auth.TouchSession(db, claims.SessionID, c.IP())
+			c.Locals("sid", claims.SessionID)
+		}
+		return c.Next()
+	}
+}
+
+// apiTokenAuth 校验个人访问令牌及其权限范围
+func apiTokenAuth(c *fiber.Ctx, db *gorm.DB, token string) error {
+	t, err := auth.LookupAPIToken(db, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPITokenInvalid) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "auth check failed"})
+	}
+	state, err := auth.LoadUserState(db, t.UserID)
+	if err == nil && state.Status != "active" {
+		err = auth.ErrUserDisabled
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = auth.ErrTokenRevoked
+	}
+	if err != nil {
+		return authError(c, err)
+	}
+
+	scope, ok := requiredScope(c.Method(), c.Path())
+	if !ok || !auth.TokenHasScope(t, scope) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":          "insufficient scope",
+			"required_scope": scope,
+		})
+	}
+
+	auth.TouchAPIToken(db, t, c.IP())
+	c.Locals("uid", t.UserID)
+	c.Locals("role", state.Role)
+	c.Locals("mfa_enabled", state.TOTPEnabled)
+	c.Locals("api_token_id", t.ID)
+	return c.Next()
+}
+
+func authError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrUserDisabled):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "account disabled"})
+	case errors.Is(err, auth.ErrTokenRevoked):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token revoked"})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "auth check failed"})
+	}
+}
+
+// AuthOptional 可选认证：携带有效 token 时写入用户信息，否则按匿名访问放行
+func AuthOptional(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return c.Next()
+		}
+		token := strings.TrimPrefix(header, "Bearer ")
+		if auth.IsAPIToken(token) {
+			// 个人访问令牌无效或权限不足时按匿名处理
+			if t, err := auth.LookupAPIToken(db, token); err == nil {
+				scope, ok := requiredScope(c.Method(), c.Path())
+				state, err := auth.LoadUserState(db, t.UserID)
+				if ok && auth.TokenHasScope(t, scope) && err == nil && state.Status == "active" {
+					auth.TouchAPIToken(db, t, c.IP())
+					c.Locals("uid", t.UserID)
+					c.Locals("role", state.Role)
+					c.Locals("api_token_id", t.ID)
+				}
+			}
+			return c.Next()
+		}
+		claims, err := auth.ParseToken(token)
+		if err != nil {
+			return c.Next()
+		}
+		if state, err := auth.ValidateClaims(db, claims); err == nil {
+			c.Locals("uid", claims.UserID)
+			c.Locals("role", state.Role)
+		}
 		return c.Next()
 	}
 }
@@ -4,6 +4,11 @@ import (
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/rbac"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
 )
 
 // RequireRoles checks if user role in context is within allowed roles
@@ -30,9 +35,27 @@ func RequireRoles(allowed ...string) fiber.Handler {
 	}
 }
 
-// AdminRequired 要求管理员权限
-func AdminRequired() fiber.Handler {
-	return RequireRoles("admin", "super_admin", "editor", "reviewer")
+// AdminRequired 要求可进入管理后台（角色至少拥有一项权限，见 rbac.IsStaff）；
+// 系统开启 require_admin_2fa 时还要求已启用两步验证
+func AdminRequired(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		staff, err := rbac.IsStaff(db, repo.Role(role))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "permission check failed"})
+		}
+		if !staff {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		}
+		mfa, _ := c.Locals("mfa_enabled").(bool)
+		if !mfa && settings.Bool(db, settings.RequireAdmin2FA, false) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":                   "two-factor authentication required",
+				"mfa_enrollment_required": true,
+			})
+		}
+		return c.Next()
+	}
 }
 
 // SuperAdminRequired 要求超级管理员权限
@@ -40,12 +63,20 @@ func SuperAdminRequired() fiber.Handler {
 	return RequireRoles("super_admin")
 }
 
-// EditorRequired 要求编辑权限
-func EditorRequired() fiber.Handler {
-	return RequireRoles("admin", "super_admin", "editor")
-}
-
-// ReviewerRequired 要求审核员权限
-func ReviewerRequired() fiber.Handler {
-	return RequireRoles("admin", "super_admin", "reviewer")
+// RequirePermission 要求当前角色拥有指定权限，授权关系见 rbac 包
+func RequirePermission(db *gorm.DB, perm string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		ok, err := rbac.Has(db, repo.Role(role), perm)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "permission check failed"})
+		}
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":      "forbidden",
+				"permission": perm,
+			})
+		}
+		return c.Next()
+	}
 }
@@ -0,0 +1,87 @@
+package middleware
+
+import "strings"
+
+// scopeRule 把路由前缀映射到个人访问令牌所需的权限范围。
+// GET/HEAD 请求需要 read，其余方法需要 write；为空表示令牌不可访问。
+type scopeRule struct {
+	pattern string
+	read    string
+	write   string
+}
+
+// scopeRules 未匹配到的路径一律拒绝，匹配时取段数最多的规则。
+// 账号安全相关接口（改密、两步验证、令牌与会话管理、管理员管理）不对令牌开放。
+var scopeRules = []scopeRule{
+	{"/me", "profile:read", ""},
+	{"/profile", "profile:read", "profile:write"},
+	{"/profile/password", "", ""},
+	{"/profile/2fa", "", ""},
+	{"/profile/tokens", "", ""},
+	{"/profile/sessions", "", ""},
+	{"/profile/works", "works:read", ""},
+	{"/profile/liked-works", "works:read", ""},
+	{"/profile/activities", "activities:read", ""},
+	{"/upload/avatar", "", "profile:write"},
+	{"/works", "works:read", "works:write"},
+	{"/works/:id/comments", "comments:read", "comments:write"},
+	{"/comments", "", "comments:write"},
+	{"/comments/:id/replies", "comments:read", "comments:write"},
+	{"/activities", "activities:read", "activities:write"},
+	{"/messages", "messages:read", "messages:write"},
+
+	{"/admin/dashboard", "admin:stats", "admin:stats"},
+	{"/admin/statistics", "admin:stats", "admin:stats"},
+	{"/admin/users", "admin:users", "admin:users"},
+	{"/admin/users/:id/sessions", "", ""},
+	{"/admin/security", "admin:users", "admin:users"},
+	{"/admin/invite-codes", "admin:users", "admin:users"},
+	{"/admin/api-tokens", "admin:users", "admin:users"},
+	{"/admin/works", "admin:works", "admin:works"},
+	{"/admin/comments", "admin:comments", "admin:comments"},
+	{"/admin/moderation", "admin:comments", "admin:comments"},
+	{"/admin/activities", "admin:activities", "admin:activities"},
+	{"/admin/announcements", "admin:announcements", "admin:announcements"},
+	{"/admin/carousels", "admin:content", "admin:content"},
+	{"/admin/materials", "admin:content", "admin:content"},
+	{"/admin/settings", "admin:settings", "admin:settings"},
+}
+
+// requiredScope 返回访问该请求所需的权限范围，ok=false 表示令牌不可访问
+func requiredScope(method, path string) (string, bool) {
+	path = strings.TrimPrefix(path, "/api/v1")
+	segs := splitPath(path)
+
+	best := -1
+	var rule scopeRule
+	for _, r := range scopeRules {
+		p := splitPath(r.pattern)
+		if len(p) > len(segs) || len(p) <= best {
+			continue
+		}
+		matched := true
+		for i := range p {
+			if !strings.HasPrefix(p[i], ":") && p[i] != segs[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			best = len(p)
+			rule = r
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+
+	scope := rule.write
+	if method == "GET" || method == "HEAD" {
+		scope = rule.read
+	}
+	return scope, scope != ""
+}
+
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' })
+}
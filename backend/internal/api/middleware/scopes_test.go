@@ -0,0 +1,38 @@
+package middleware
+
+import "testing"
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		scope  string
+		ok     bool
+	}{
+		{"读请求取 read 范围", "GET", "/api/v1/works", "works:read", true},
+		{"HEAD 按读请求处理", "HEAD", "/api/v1/works/3", "works:read", true},
+		{"写请求取 write 范围", "POST", "/api/v1/works", "works:write", true},
+		{"前缀匹配到子路径", "DELETE", "/api/v1/works/3/like", "works:write", true},
+		{"参数段匹配任意值", "GET", "/api/v1/works/42/comments", "comments:read", true},
+		{"取段数最多的规则", "GET", "/api/v1/profile/works", "works:read", true},
+		{"更长的规则为空时拒绝", "PUT", "/api/v1/profile/password", "", false},
+		{"两步验证接口不对令牌开放", "POST", "/api/v1/profile/2fa/enable", "", false},
+		{"令牌管理接口不对令牌开放", "GET", "/api/v1/profile/tokens", "", false},
+		{"只读规则拒绝写请求", "POST", "/api/v1/me", "", false},
+		{"管理接口", "PUT", "/api/v1/admin/users/7/role", "admin:users", true},
+		{"管理员会话管理不对令牌开放", "DELETE", "/api/v1/admin/users/7/sessions", "", false},
+		{"未列出的路径一律拒绝", "GET", "/api/v1/auth/sessions", "", false},
+		{"段名需完全相同", "GET", "/api/v1/worksx", "", false},
+		{"没有 /api/v1 前缀也能匹配", "GET", "/messages", "messages:read", true},
+		{"末尾斜杠", "GET", "/api/v1/activities/", "activities:read", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, ok := requiredScope(tt.method, tt.path)
+			if scope != tt.scope || ok != tt.ok {
+				t.Errorf("requiredScope(%s %s) = %q, %v, want %q, %v", tt.method, tt.path, scope, ok, tt.scope, tt.ok)
+			}
+		})
+	}
+}
@@ -6,54 +6,101 @@ import (
 
 	"maimang/backend/internal/api/handlers"
 	"maimang/backend/internal/api/middleware"
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/rbac"
+	"maimang/backend/internal/realtime"
 )
 
-func RegisterRoutes(app *fiber.App, db *gorm.DB) {
+func RegisterRoutes(app *fiber.App, db *gorm.DB, m mailer.Mailer, hub *realtime.Hub) {
 	v1 := app.Group("/api/v1")
 
+	// 访问令牌校验公钥
+	app.Get("/.well-known/jwks.json", handlers.JWKS())
+
 	// 认证相关 API
-	v1.Post("/auth/register", handlers.Register(db))
+	v1.Get("/auth/registration", handlers.GetRegistrationMode(db))
+	v1.Post("/auth/register", handlers.Register(db, m))
 	v1.Post("/auth/login", handlers.Login(db))
-	v1.Post("/auth/refresh", handlers.Refresh())
-	v1.Get("/me", middleware.AuthRequired(), handlers.Me())
+	v1.Post("/auth/login/mfa", handlers.LoginMFA(db))
+	v1.Post("/auth/refresh", handlers.Refresh(db))
+	v1.Post("/auth/logout", handlers.Logout(db))
+	v1.Post("/auth/logout-all", middleware.AuthRequired(db), handlers.LogoutAll(db))
+	v1.Post("/auth/password/forgot", handlers.ForgotPassword(db, m))
+	v1.Post("/auth/password/reset", handlers.ResetPassword(db))
+	v1.Post("/auth/verify-email", handlers.VerifyEmail(db))
+	v1.Get("/auth/oidc/providers", handlers.ListOIDCProviders())
+	v1.Get("/auth/oidc/:provider/start", handlers.OIDCStart(db))
+	v1.Get("/auth/oidc/:provider/callback", handlers.OIDCCallback(db))
+	v1.Post("/auth/oidc/exchange", handlers.OIDCExchange(db))
+	v1.Post("/auth/verify-email/resend", middleware.AuthRequired(db), handlers.ResendVerification(db, m))
+	v1.Get("/me", middleware.AuthRequired(db), handlers.Me(db))
 
 	// 文件上传 API
-	v1.Post("/upload/avatar", middleware.AuthRequired(), handlers.UploadAvatar())
+	v1.Post("/upload/avatar", middleware.AuthRequired(db), handlers.UploadAvatar())
 
 	// 个人中心 API
-	profile := v1.Group("/profile", middleware.AuthRequired())
+	profile := v1.Group("/profile", middleware.AuthRequired(db))
 	profile.Get("/", handlers.GetProfile(db))
 	profile.Put("/", handlers.UpdateProfile(db))
+	profile.Put("/password", handlers.ChangePassword(db))
+	profile.Get("/2fa", handlers.GetTwoFactorStatus(db))
+	profile.Post("/2fa/setup", handlers.SetupTwoFactor(db))
+	profile.Post("/2fa/enable", handlers.EnableTwoFactor(db))
+	profile.Post("/2fa/disable", handlers.DisableTwoFactor(db))
+	profile.Post("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes(db))
+	profile.Get("/tokens", handlers.ListMyAPITokens(db))
+	profile.Post("/tokens", handlers.CreateAPIToken(db))
+	profile.Put("/tokens/:id", handlers.UpdateAPIToken(db))
+	profile.Delete("/tokens/:id", handlers.RevokeMyAPIToken(db))
+	profile.Get("/sessions", handlers.ListMySessions(db))
+	profile.Delete("/sessions/:id", handlers.RevokeMySession(db))
 	profile.Get("/works", handlers.GetMyWorks(db))
+	profile.Get("/liked-works", handlers.GetMyLikedWorks(db))
 	profile.Get("/activities", handlers.GetMyActivities(db))
 	profile.Get("/notifications", handlers.GetNotifications(db))
+	profile.Get("/notifications/unread-count", handlers.GetUnreadNotificationCount(db))
+	profile.Put("/notifications/read-all", handlers.MarkAllNotificationsRead(db))
+	profile.Put("/notifications/:id/read", handlers.MarkNotificationRead(db))
+	profile.Get("/notification-settings", handlers.GetNotificationSettings(db))
+	profile.Put("/notification-settings", handlers.UpdateNotificationSettings(db))
+	profile.Get("/privacy", handlers.GetPrivacySettings(db))
+	profile.Put("/privacy", handlers.UpdatePrivacySettings(db))
+	profile.Get("/blocks", handlers.ListBlocks(db))
+	profile.Post("/blocks", handlers.BlockUser(db))
+	profile.Delete("/blocks/:id", handlers.UnblockUser(db))
+	profile.Get("/following", handlers.ListFollowing(db))
+	profile.Post("/following", handlers.FollowUser(db))
+	profile.Delete("/following/:id", handlers.UnfollowUser(db))
 
 	// 作品管理 API
 	works := v1.Group("/works")
-	works.Get("/", handlers.ListWorks(db))
-	works.Get("/:id", handlers.GetWork(db))
-	works.Post("/", middleware.AuthRequired(), handlers.CreateWork(db))
-	works.Put("/:id", middleware.AuthRequired(), handlers.UpdateWork(db))
-	works.Delete("/:id", middleware.AuthRequired(), handlers.DeleteWork(db))
-	works.Post("/:id/like", middleware.AuthRequired(), handlers.LikeWork(db))
-	works.Delete("/:id/like", middleware.AuthRequired(), handlers.UnlikeWork(db))
+	works.Get("/", middleware.AuthOptional(db), handlers.ListWorks(db))
+	works.Get("/:id", middleware.AuthOptional(db), handlers.GetWork(db))
+	works.Post("/", middleware.AuthRequired(db), handlers.CreateWork(db))
+	works.Put("/:id", middleware.AuthRequired(db), handlers.UpdateWork(db))
+	works.Delete("/:id", middleware.AuthRequired(db), handlers.DeleteWork(db))
+	works.Post("/:id/like", middleware.AuthRequired(db), handlers.LikeWork(db))
+	works.Delete("/:id/like", middleware.AuthRequired(db), handlers.UnlikeWork(db))
 
 	// 评论管理 API
 	comments := v1.Group("/works/:id/comments")
-	comments.Get("/", handlers.ListWorkComments(db))
-	comments.Post("/", middleware.AuthRequired(), handlers.CreateComment(db))
+	comments.Get("/", middleware.AuthOptional(db), handlers.ListWorkComments(db))
+	comments.Post("/", middleware.AuthRequired(db), handlers.CreateComment(db))
 
 	commentsById := v1.Group("/comments/:id")
-	commentsById.Put("/", middleware.AuthRequired(), handlers.UpdateComment(db))
-	commentsById.Delete("/", middleware.AuthRequired(), handlers.DeleteComment(db))
-	commentsById.Post("/like", middleware.AuthRequired(), handlers.LikeComment(db))
+	commentsById.Put("/", middleware.AuthRequired(db), handlers.UpdateComment(db))
+	commentsById.Delete("/", middleware.AuthRequired(db), handlers.DeleteComment(db))
+	commentsById.Post("/like", middleware.AuthRequired(db), handlers.LikeComment(db))
+	commentsById.Delete("/like", middleware.AuthRequired(db), handlers.UnlikeComment(db))
+	commentsById.Get("/replies", middleware.AuthOptional(db), handlers.ListCommentReplies(db))
+	commentsById.Post("/replies", middleware.AuthRequired(db), handlers.CreateReply(db))
 
 	// 活动管理 API
 	activities := v1.Group("/activities")
 	activities.Get("/", handlers.ListActivities(db))
 	activities.Get("/:id", handlers.GetActivity(db))
-	activities.Post("/:id/register", middleware.AuthRequired(), handlers.RegisterActivity(db))
-	activities.Delete("/:id/register", middleware.AuthRequired(), handlers.UnregisterActivity(db))
+	activities.Post("/:id/register", middleware.AuthRequired(db), handlers.RegisterActivity(db))
+	activities.Delete("/:id/register", middleware.AuthRequired(db), handlers.UnregisterActivity(db))
 
 	// 公开内容 API
 	v1.Get("/articles", handlers.ListArticles(db))
@@ -65,73 +112,98 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB) {
 	v1.Get("/carousels", handlers.ListCarousels(db))         // 公开轮播图
 	v1.Get("/announcements", handlers.ListAnnouncements(db)) // 公开公告
 	// 公开系统设置（用于首页）
-	v1.Get("/settings", handlers.GetSystemSettings(db))
+	v1.Get("/settings", handlers.GetPublicSystemSettings(db))
 
 	// 公共统计
 	v1.Get("/stats", handlers.GetPublicStatsSummary(db))
 
 	// 管理员 API
-	admin := v1.Group("/admin", middleware.AuthRequired(), middleware.AdminRequired())
+	admin := v1.Group("/admin", middleware.AuthRequired(db), middleware.AdminRequired(db))
+	perm := func(p string) fiber.Handler { return middleware.RequirePermission(db, p) }
 
 	// 仪表盘和统计
-	admin.Get("/dashboard", handlers.GetDashboardStats(db))
-	admin.Get("/statistics", handlers.GetDashboardStats(db))
-	admin.Get("/statistics/users", handlers.GetUserStats(db))
-	admin.Get("/statistics/works", handlers.GetWorkStats(db))
-	admin.Get("/statistics/activities", handlers.GetActivityStats(db))
-	admin.Get("/statistics/comments", handlers.GetCommentStats(db))
+	admin.Get("/dashboard", perm(rbac.DashboardView), handlers.GetDashboardStats(db))
+	admin.Get("/statistics", perm(rbac.DashboardView), handlers.GetDashboardStats(db))
+	admin.Get("/statistics/users", perm(rbac.DashboardView), handlers.GetUserStats(db))
+	admin.Get("/statistics/works", perm(rbac.DashboardView), handlers.GetWorkStats(db))
+	admin.Get("/statistics/activities", perm(rbac.DashboardView), handlers.GetActivityStats(db))
+	admin.Get("/statistics/comments", perm(rbac.DashboardView), handlers.GetCommentStats(db))
 
 	// 详细统计数据
-	admin.Get("/statistics/user-growth", handlers.GetUserGrowthStats(db))
-	admin.Get("/statistics/content-trend", handlers.GetContentTrendStats(db))
-	admin.Get("/statistics/activity-participation", handlers.GetActivityParticipationStats(db))
-	admin.Get("/statistics/monthly", handlers.GetMonthlyStats(db))
+	admin.Get("/statistics/user-growth", perm(rbac.DashboardView), handlers.GetUserGrowthStats(db))
+	admin.Get("/statistics/content-trend", perm(rbac.DashboardView), handlers.GetContentTrendStats(db))
+	admin.Get("/statistics/activity-participation", perm(rbac.DashboardView), handlers.GetActivityParticipationStats(db))
+	admin.Get("/statistics/monthly", perm(rbac.DashboardView), handlers.GetMonthlyStats(db))
 
 	// 用户管理
-	admin.Get("/users", handlers.ListUsers(db))
-	admin.Get("/users/:id", handlers.GetUser(db))
-	admin.Put("/users/:id", handlers.UpdateUser(db))
-	admin.Delete("/users/:id", handlers.DeleteUser(db))
-	admin.Put("/users/:id/status", handlers.UpdateUserStatus(db))
-	admin.Put("/users/:id/ban", handlers.BanUser(db))
-	admin.Put("/users/:id/unban", handlers.UnbanUser(db))
+	admin.Get("/users", perm(rbac.UsersRead), handlers.ListUsers(db))
+	admin.Get("/users/pending", perm(rbac.UsersApprove), handlers.ListPendingUsers(db))
+	admin.Get("/users/:id", perm(rbac.UsersRead), handlers.GetUser(db))
+	admin.Put("/users/:id", perm(rbac.UsersWrite), handlers.UpdateUser(db))
+	admin.Delete("/users/:id", perm(rbac.UsersDelete), handlers.DeleteUser(db))
+	admin.Put("/users/:id/status", perm(rbac.UsersBan), handlers.UpdateUserStatus(db))
+	admin.Put("/users/:id/ban", perm(rbac.UsersBan), handlers.BanUser(db))
+	admin.Put("/users/:id/unban", perm(rbac.UsersBan), handlers.UnbanUser(db))
+	admin.Put("/users/:id/approve", perm(rbac.UsersApprove), handlers.ApproveUser(db, m))
+	admin.Put("/users/:id/reject", perm(rbac.UsersApprove), handlers.RejectUser(db, m))
+	admin.Get("/users/:id/sessions", perm(rbac.SecurityManage), handlers.ListUserSessions(db))
+	admin.Delete("/users/:id/sessions", perm(rbac.SecurityManage), handlers.RevokeUserSessions(db))
+	admin.Delete("/users/:id/sessions/:sid", perm(rbac.SecurityManage), handlers.RevokeUserSession(db))
+
+	// 邀请码
+	admin.Get("/invite-codes", perm(rbac.InvitesManage), handlers.ListInviteCodes(db))
+	admin.Post("/invite-codes", perm(rbac.InvitesManage), handlers.CreateInviteCodes(db))
+	admin.Delete("/invite-codes/:id", perm(rbac.InvitesManage), handlers.RevokeInviteCode(db))
+
+	// 登录安全
+	admin.Get("/security/lockouts", perm(rbac.SecurityManage), handlers.ListLoginLockouts(db))
+	admin.Delete("/security/lockouts/:id", perm(rbac.SecurityManage), handlers.ClearLoginLockout(db))
+	admin.Get("/security/lockout-events", perm(rbac.SecurityManage), handlers.ListLockoutEvents(db))
+	admin.Get("/api-tokens", perm(rbac.SecurityManage), handlers.ListAPITokens(db))
+	admin.Delete("/api-tokens/:id", perm(rbac.SecurityManage), handlers.RevokeAPIToken(db))
 
 	// 作品审核
-	admin.Get("/works", handlers.ListPendingWorks(db))
-	admin.Put("/works/:id/approve", handlers.ReviewWork(db))
-	admin.Put("/works/:id/reject", handlers.ReviewWork(db))
-	admin.Put("/works/:id/review", handlers.UpdateWorkReview(db))
+	admin.Get("/works", perm(rbac.WorksReview), handlers.ListPendingWorks(db))
+	admin.Put("/works/:id/approve", perm(rbac.WorksReview), handlers.ReviewWork(db))
+	admin.Put("/works/:id/reject", perm(rbac.WorksReview), handlers.ReviewWork(db))
+	admin.Put("/works/:id/review", perm(rbac.WorksReview), handlers.UpdateWorkReview(db))
 
 	// 评论审核
-	admin.Get("/comments", handlers.ListPendingComments(db))
-	admin.Get("/comments/:id", handlers.GetAdminComment(db))
-	admin.Put("/comments/:id/approve", handlers.ReviewComment(db))
-	admin.Put("/comments/:id/reject", handlers.ReviewComment(db))
-	admin.Put("/comments/:id/hide", handlers.ReviewComment(db))
-	admin.Put("/comments/:id/unhide", handlers.ReviewComment(db))
-	admin.Put("/comments/:id/pend", handlers.ReviewComment(db))
+	admin.Get("/comments", perm(rbac.CommentsReview), handlers.ListPendingComments(db))
+	admin.Get("/comments/:id", perm(rbac.CommentsReview), handlers.GetAdminComment(db))
+	admin.Put("/comments/:id/approve", perm(rbac.CommentsReview), handlers.ReviewComment(db))
+	admin.Put("/comments/:id/reject", perm(rbac.CommentsReview), handlers.ReviewComment(db))
+	admin.Put("/comments/:id/hide", perm(rbac.CommentsReview), handlers.ReviewComment(db))
+	admin.Put("/comments/:id/unhide", perm(rbac.CommentsReview), handlers.ReviewComment(db))
+	admin.Put("/comments/:id/pend", perm(rbac.CommentsReview), handlers.ReviewComment(db))
+
+	// 私信举报
+	admin.Get("/moderation/cases", perm(rbac.ModerationManage), handlers.ListModerationCases(db))
+	admin.Get("/moderation/cases/:id", perm(rbac.ModerationManage), handlers.GetModerationCase(db))
+	admin.Put("/moderation/cases/:id", perm(rbac.ModerationManage), handlers.UpdateModerationCase(db))
+	admin.Get("/moderation/cases/:id/attachments/:aid", perm(rbac.ModerationManage), handlers.GetModerationAttachment(db))
 
 	// 活动管理
-	admin.Get("/activities", handlers.ListAdminActivities(db))
-	admin.Post("/activities", handlers.CreateActivity(db))
-	admin.Put("/activities/:id", handlers.UpdateActivity(db))
-	admin.Delete("/activities/:id", handlers.DeleteActivity(db))
-	admin.Get("/activities/:id/participants", handlers.GetActivityParticipants(db))
-	admin.Put("/activities/:id/status", handlers.UpdateActivityStatus(db))
+	admin.Get("/activities", perm(rbac.ActivitiesManage), handlers.ListAdminActivities(db))
+	admin.Post("/activities", perm(rbac.ActivitiesManage), handlers.CreateActivity(db))
+	admin.Put("/activities/:id", perm(rbac.ActivitiesManage), handlers.UpdateActivity(db))
+	admin.Delete("/activities/:id", perm(rbac.ActivitiesManage), handlers.DeleteActivity(db))
+	admin.Get("/activities/:id/participants", perm(rbac.ActivitiesParticipant), handlers.GetActivityParticipants(db))
+	admin.Put("/activities/:id/status", perm(rbac.ActivitiesManage), handlers.UpdateActivityStatus(db))
 
 	// 轮播图管理
-	admin.Get("/carousels", handlers.ListCarousels(db))
-	admin.Post("/carousels", handlers.CreateCarousel(db))
-	admin.Put("/carousels/:id", handlers.UpdateCarousel(db))
-	admin.Delete("/carousels/:id", handlers.DeleteCarousel(db))
-	admin.Put("/carousels/:id/order", handlers.UpdateCarouselOrder(db))
+	admin.Get("/carousels", perm(rbac.CarouselsManage), handlers.ListCarousels(db))
+	admin.Post("/carousels", perm(rbac.CarouselsManage), handlers.CreateCarousel(db))
+	admin.Put("/carousels/:id", perm(rbac.CarouselsManage), handlers.UpdateCarousel(db))
+	admin.Delete("/carousels/:id", perm(rbac.CarouselsManage), handlers.DeleteCarousel(db))
+	admin.Put("/carousels/:id/order", perm(rbac.CarouselsManage), handlers.UpdateCarouselOrder(db))
 
 	// 公告管理
-	admin.Get("/announcements", handlers.ListAnnouncements(db))
-	admin.Post("/announcements", handlers.CreateAnnouncement(db))
-	admin.Put("/announcements/:id", handlers.UpdateAnnouncement(db))
-	admin.Delete("/announcements/:id", handlers.DeleteAnnouncement(db))
-	admin.Put("/announcements/:id/publish", handlers.PublishAnnouncement(db))
+	admin.Get("/announcements", perm(rbac.AnnouncementsManage), handlers.ListAnnouncements(db))
+	admin.Post("/announcements", perm(rbac.AnnouncementsManage), handlers.CreateAnnouncement(db))
+	admin.Put("/announcements/:id", perm(rbac.AnnouncementsManage), handlers.UpdateAnnouncement(db))
+	admin.Delete("/announcements/:id", perm(rbac.AnnouncementsManage), handlers.DeleteAnnouncement(db))
+	admin.Put("/announcements/:id/publish", perm(rbac.AnnouncementsManage), handlers.PublishAnnouncement(db))
 
 	// 管理员管理（超级管理员）
 	superAdmin := admin.Group("/admins", middleware.SuperAdminRequired())
@@ -140,26 +212,41 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB) {
 	superAdmin.Put("/:id", handlers.UpdateAdmin(db))
 	superAdmin.Delete("/:id", handlers.DeleteAdmin(db))
 
+	// 角色授权（超级管理员）
+	roles := admin.Group("/roles", middleware.SuperAdminRequired())
+	roles.Get("/", handlers.ListRolePermissions(db))
+	roles.Get("/permissions", handlers.ListPermissions())
+	roles.Put("/:role", handlers.UpdateRolePermissions(db))
+
 	// 系统设置
-	admin.Get("/settings", handlers.GetSystemSettings(db))
-	admin.Put("/settings", handlers.UpdateSystemSettings(db))
-	admin.Get("/settings/:key", handlers.GetSystemSetting(db))
-	admin.Put("/settings/:key", handlers.UpdateSystemSetting(db))
+	admin.Get("/settings", perm(rbac.SettingsRead), handlers.GetSystemSettings(db))
+	admin.Put("/settings", perm(rbac.SettingsWrite), handlers.UpdateSystemSettings(db))
+	admin.Get("/settings/:key", perm(rbac.SettingsRead), handlers.GetSystemSetting(db))
+	admin.Put("/settings/:key", perm(rbac.SettingsWrite), handlers.UpdateSystemSetting(db))
 
 	// 素材管理
-	admin.Get("/materials", handlers.ListMaterials(db))
-	admin.Post("/materials", handlers.CreateMaterial(db))
-	admin.Post("/materials/upload", handlers.UploadMaterial(db))
-	admin.Put("/materials/:id", handlers.UpdateMaterial(db))
-	admin.Delete("/materials/:id", handlers.DeleteMaterial(db))
+	admin.Get("/materials", perm(rbac.MaterialsManage), handlers.ListMaterials(db))
+	admin.Post("/materials", perm(rbac.MaterialsManage), handlers.CreateMaterial(db))
+	admin.Post("/materials/upload", perm(rbac.MaterialsManage), handlers.UploadMaterial(db))
+	admin.Put("/materials/:id", perm(rbac.MaterialsManage), handlers.UpdateMaterial(db))
+	admin.Delete("/materials/:id", perm(rbac.MaterialsManage), handlers.DeleteMaterial(db))
 
 	// 静态文件服务
 	app.Get("/uploads/*", handlers.ServeStaticFiles())
 
 	// 私信/消息 API（登录用户）
-	messages := v1.Group("/messages", middleware.AuthRequired())
+	messages := v1.Group("/messages", middleware.AuthRequired(db))
 	messages.Get("/conversations", handlers.ListConversations(db))
+	messages.Get("/unread-count", handlers.GetUnreadMessageCount(db))
+	messages.Post("/attachments", handlers.UploadMessageAttachment(db))
+	messages.Get("/attachments/:id", handlers.DownloadMessageAttachment(db))
+	messages.Post("/items/:mid/unsend", handlers.UnsendMessage(db))
+	messages.Delete("/items/:mid", handlers.DeleteMessageForMe(db))
 	messages.Get("/:id", handlers.ListMessagesWith(db))
 	messages.Post("/:id", handlers.SendMessage(db))
 	messages.Put("/:id/read", handlers.MarkMessagesRead(db))
+	messages.Post("/:id/report", handlers.ReportConversation(db))
+
+	// 实时事件流（SSE），自行校验令牌以支持 ?access_token=；不能用 /events，该路径是活动列表
+	v1.Get("/realtime/events", handlers.Events(db, hub))
 }
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+)
+
+// APITokenPrefix 个人访问令牌的固定前缀，用于与 JWT 区分
+const APITokenPrefix = "mm_pat_"
+
+var (
+	ErrAPITokenInvalid = errors.New("invalid api token")
+	ErrUnknownScope    = errors.New("unknown scope")
+)
+
+// 普通用户可申请的权限范围
+var userScopes = []string{
+	"profile:read", "profile:write",
+	"works:read", "works:write",
+	"comments:read", "comments:write",
+	"activities:read", "activities:write",
+	"messages:read", "messages:write",
+}
+
+// 仅管理人员可申请的权限范围
+var adminScopes = []string{
+	"admin:stats",
+	"admin:users",
+	"admin:works",
+	"admin:comments",
+	"admin:activities",
+	"admin:announcements",
+	"admin:content",
+	"admin:settings",
+}
+
+// AvailableScopes 返回可申请的权限范围；staff 表示可进入管理后台（见 rbac.IsStaff）
+func AvailableScopes(staff bool) []string {
+	scopes := append([]string{}, userScopes...)
+	if staff {
+		scopes = append(scopes, adminScopes...)
+	}
+	return scopes
+}
+
+// NormalizeScopes 去重排序并校验权限范围是否允许申请
+func NormalizeScopes(staff bool, scopes []string) ([]string, error) {
+	allowed := map[string]bool{}
+	for _, s := range AvailableScopes(staff) {
+		allowed[s] = true
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		if !allowed[s] {
+			return nil, ErrUnknownScope
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, ErrUnknownScope
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// IsAPIToken 判断凭据是否为个人访问令牌
+func IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, APITokenPrefix)
+}
+
+// IssueAPIToken 创建个人访问令牌，明文只在返回值中出现一次
+func IssueAPIToken(db *gorm.DB, uid uint, name string, scopes []string, expiresAt *time.Time) (string, *repo.APIToken, error) {
+	secret, err := RandomToken(30)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := APITokenPrefix + secret
+	t := &repo.APIToken{
+		UserID:    uid,
+		Name:      name,
+		Prefix:    raw[:len(APITokenPrefix)+6],
+		TokenHash: HashToken(raw),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(t).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, t, nil
+}
+
+// LookupAPIToken 查找未吊销且未过期的个人访问令牌
+func LookupAPIToken(db *gorm.DB, raw string) (*repo.APIToken, error) {
+	var t repo.APIToken
+	err := db.Where("token_hash = ? AND revoked_at IS NULL", HashToken(raw)).First(&t).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPITokenInvalid
+		}
+		return nil, err
+	}
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return nil, ErrAPITokenInvalid
+	}
+	return &t, nil
+}
+
+// TokenHasScope 判断令牌是否包含指定权限范围
+func TokenHasScope(t *repo.APIToken, scope string) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 最近使用时间最多每分钟写一次库
+const apiTokenTouchInterval = time.Minute
+
+var apiTokenTouches sync.Map // token id -> time.Time
+
+// TouchAPIToken 记录令牌最近使用时间与来源 IP
+func TouchAPIToken(db *gorm.DB, t *repo.APIToken, ip string) {
+	now := time.Now()
+	if last, ok := apiTokenTouches.Load(t.ID); ok && now.Sub(last.(time.Time)) < apiTokenTouchInterval {
+		return
+	}
+	apiTokenTouches.Store(t.ID, now)
+	db.Model(&repo.APIToken{}).Where("id = ?", t.ID).
+		UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+}
+
+// RevokeAPIToken 吊销个人访问令牌；by 为执行吊销的管理员，本人吊销时为 nil
+func RevokeAPIToken(db *gorm.DB, id uint, by *uint) error {
+	res := db.Model(&repo.APIToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_by": by})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeUserAPITokens 吊销用户的全部个人访问令牌
+func RevokeUserAPITokens(db *gorm.DB, uid uint) error {
+	return db.Model(&repo.APIToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"maimang/backend/internal/repo"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		staff   bool
+		scopes  []string
+		want    []string
+		wantErr error
+	}{
+		{"去重排序并去掉空白", false, []string{" works:write", "profile:read", "works:write", ""}, []string{"profile:read", "works:write"}, nil},
+		{"普通用户不能申请管理范围", false, []string{"works:read", "admin:users"}, nil, ErrUnknownScope},
+		{"管理人员可以申请管理范围", true, []string{"admin:users", "works:read"}, []string{"admin:users", "works:read"}, nil},
+		{"未知范围", true, []string{"works:delete"}, nil, ErrUnknownScope},
+		{"不能为空", false, []string{" ", ""}, nil, ErrUnknownScope},
+		{"范围需完全相同", false, []string{"works"}, nil, ErrUnknownScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeScopes(tt.staff, tt.scopes)
+			if !errors.Is(err, tt.wantErr) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeScopes(%v) = %v, %v, want %v, %v", tt.scopes, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenHasScope(t *testing.T) {
+	tok := &repo.APIToken{Scopes: "profile:read,works:write"}
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"works:write", true},
+		{"profile:read", true},
+		{"works:read", false},
+		{"works", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := TokenHasScope(tok, tt.scope); got != tt.want {
+			t.Errorf("TokenHasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
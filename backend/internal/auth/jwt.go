@@ -7,51 +7,95 @@ import (
 )
 
 type Claims struct {
-	UserID uint   `json:"uid"`
-	Role   string `json:"role"`
+	UserID  uint   `json:"uid"`
+	Role    string `json:"role"`
+	Version int    `json:"ver"`
+	// SessionID 登录会话 ID；个人访问令牌和旧令牌没有
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(secret string, uid uint, role string, ttl time.Duration) (string, error) {
+// Issuer 写入令牌 iss 字段，其他服务校验时使用
+var Issuer = "maimang"
+
+func GenerateAccessToken(uid uint, role string, version int, sessionID string, ttl time.Duration) (string, error) {
+	ks := CurrentKeySet()
+	if ks == nil {
+		return "", ErrNoSigningKey
+	}
 	claims := &Claims{
-		UserID: uid,
-		Role:   role,
+		UserID:    uid,
+		Role:      role,
+		Version:   version,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return ks.sign(claims)
 }
 
-func ParseToken(secret, tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+func ParseToken(tokenStr string) (*Claims, error) {
+	claims, err := parseClaims(tokenStr)
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	// 两步登录的临时令牌不能当作访问令牌使用
+	if claims.Subject == mfaSubject {
+		return nil, jwt.ErrTokenInvalidClaims
 	}
-	return nil, jwt.ErrTokenInvalidClaims
+	return claims, nil
 }
 
-func GenerateRefreshToken(secret string, uid uint, role string, ttl time.Duration) (string, error) {
+const mfaSubject = "mfa"
+
+// GenerateMFAToken 签发两步登录的临时令牌，仅用于 /auth/login/mfa 换取正式令牌
+func GenerateMFAToken(uid uint, ttl time.Duration) (string, error) {
+	ks := CurrentKeySet()
+	if ks == nil {
+		return "", ErrNoSigningKey
+	}
 	claims := &Claims{
 		UserID: uid,
-		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   "refresh",
+			Issuer:    Issuer,
+			Subject:   mfaSubject,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return ks.sign(claims)
+}
+
+// ParseMFAToken 解析两步登录临时令牌
+func ParseMFAToken(tokenStr string) (*Claims, error) {
+	claims, err := parseClaims(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != mfaSubject {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
 }
 
-func IsRefresh(claims *Claims) bool {
-	return claims != nil && claims.Subject == "refresh"
+// parseClaims 按 kid 选择公钥校验签名，只接受非对称算法
+func parseClaims(tokenStr string) (*Claims, error) {
+	ks := CurrentKeySet()
+	if ks == nil {
+		return nil, ErrNoSigningKey
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, ks.keyFunc,
+		jwt.WithValidMethods([]string{AlgEdDSA, AlgRS256}),
+		jwt.WithIssuer(Issuer),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, jwt.ErrTokenInvalidClaims
 }
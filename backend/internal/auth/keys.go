@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的签名算法
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+// JWKSMaxAge JWKS 响应允许缓存的时长。新密钥生成后要过了这段时间才用于签名，
+// 保证缓存了旧 JWKS 的服务拿到新令牌前已能刷新到新公钥
+const JWKSMaxAge = 5 * time.Minute
+
+var ErrNoSigningKey = errors.New("no signing key loaded")
+
+// SigningKey 是密钥目录中的一把私钥，文件名（去掉 .pem）即 kid
+type SigningKey struct {
+	ID      string
+	Alg     string
+	Private crypto.Signer
+	Created time.Time // 密钥文件的修改时间
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Alg == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// KeySet 从目录加载的一组密钥：已发布满 JWKSMaxAge 的密钥中 kid 最大（最新）的用于签名，
+// 其余仅用于校验
+type KeySet struct {
+	dir string
+
+	mu         sync.RWMutex
+	keys       map[string]*SigningKey
+	lastReload time.Time
+}
+
+var (
+	keySetMu sync.RWMutex
+	keySet   *KeySet
+)
+
+// SetKeySet 设置全局密钥集合
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	keySet = ks
+	keySetMu.Unlock()
+}
+
+// CurrentKeySet 返回全局密钥集合
+func CurrentKeySet() *KeySet {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
+	return keySet
+}
+
+// LoadKeySet 加载目录中的所有 *.pem 私钥（PKCS#8）
+func LoadKeySet(dir string) (*KeySet, error) {
+	ks := &KeySet{dir: dir}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload 重新读取密钥目录，用于感知 `server keys rotate` 生成的新密钥
+func (ks *KeySet) Reload() error {
+	keys, err := readKeyDir(ks.dir)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.lastReload = time.Now()
+	ks.mu.Unlock()
+	return nil
+}
+
+// Active 返回当前签名密钥：已发布满 JWKSMaxAge 的最新密钥；
+// 没有这样的密钥时（如首次启动刚生成密钥）使用最新的密钥
+func (ks *KeySet) Active() (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var newest, published *SigningKey
+	for _, k := range ks.keys {
+		if newest == nil || k.ID > newest.ID {
+			newest = k
+		}
+		if time.Since(k.Created) >= JWKSMaxAge && (published == nil || k.ID > published.ID) {
+			published = k
+		}
+	}
+	if published != nil {
+		return published, nil
+	}
+	if newest == nil {
+		return nil, ErrNoSigningKey
+	}
+	return newest, nil
+}
+
+// lookup 按 kid 查找校验密钥；未知 kid 时最多每 10 秒重新读取一次目录
+func (ks *KeySet) lookup(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	k, ok := ks.keys[kid]
+	stale := time.Since(ks.lastReload) > 10*time.Second
+	ks.mu.RUnlock()
+	if ok || !stale {
+		return k, ok
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, false
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok = ks.keys[kid]
+	return k, ok
+}
+
+// Len 返回已加载的密钥数量
+func (ks *KeySet) Len() int {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return len(ks.keys)
+}
+
+// JWKS 返回所有公钥的 JSON Web Key Set
+func (ks *KeySet) JWKS() map[string]interface{} {
+	ks.mu.RLock()
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	jwks := make([]map[string]string, 0, len(ids))
+	for _, id := range ids {
+		k := ks.keys[id]
+		jwk := map[string]string{"kid": k.ID, "alg": k.Alg, "use": "sig"}
+		switch pub := k.Private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+	ks.mu.RUnlock()
+	return map[string]interface{}{"keys": jwks}
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	k, err := ks.Active()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(k.method(), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.Private)
+}
+
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != k.Alg {
+		return nil, fmt.Errorf("unexpected alg %q for kid %q", t.Method.Alg(), kid)
+	}
+	return k.Private.Public(), nil
+}
+
+func readKeyDir(dir string) (map[string]*SigningKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read key dir: %w", err)
+	}
+	keys := make(map[string]*SigningKey)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pem" {
+			continue
+		}
+		// 个别文件无法读取（如正在写入或权限不对）时跳过，不影响其余密钥
+		k, err := readKeyFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			log.Printf("Skip signing key: %v", err)
+			continue
+		}
+		keys[k.ID] = k
+	}
+	return keys, nil
+}
+
+func readKeyFile(path string) (*SigningKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	k := &SigningKey{ID: strings.TrimSuffix(filepath.Base(path), ".pem"), Created: info.ModTime()}
+	switch priv := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.Alg, k.Private = AlgEdDSA, priv
+	case *rsa.PrivateKey:
+		k.Alg, k.Private = AlgRS256, priv
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, parsed)
+	}
+	return k, nil
+}
+
+// GenerateKeyFile 在目录中生成一把新私钥并返回 kid；kid 以时间开头，按字典序即按新旧排序
+func GenerateKeyFile(dir, alg string) (string, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case AlgEdDSA, "":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return "", fmt.Errorf("unsupported alg %q", alg)
+	}
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := RandomToken(3)
+	if err != nil {
+		return "", err
+	}
+	kid := time.Now().UTC().Format("20060102T150405Z") + "-" + strings.ToLower(suffix)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return "", err
+	}
+	return kid, nil
+}
+
+// PruneKeyFiles 只保留最新的 keep 把密钥，返回被删除的 kid
+func PruneKeyFiles(dir string, keep int) ([]string, error) {
+	keys, err := readKeyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	var removed []string
+	for i, id := range ids {
+		if i < keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, id+".pem")); err != nil {
+			return removed, err
+		}
+		removed = append(removed, id)
+	}
+	return removed, nil
+}
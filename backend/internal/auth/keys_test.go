@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey 在目录中生成一把指定 kid 的密钥，并把文件时间设为 age 之前
+func writeKey(t *testing.T, dir, kid, alg string, age time.Duration) {
+	t.Helper()
+	gen, err := GenerateKeyFile(dir, alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.Rename(filepath.Join(dir, gen+".pem"), path); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySetActive(t *testing.T) {
+	type key struct {
+		kid string
+		age time.Duration
+	}
+	tests := []struct {
+		name string
+		keys []key
+		want string
+	}{
+		{"首次启动只有刚生成的密钥", []key{{"k2", 0}}, "k2"},
+		{"新密钥未发布满 JWKSMaxAge 时继续用旧密钥", []key{{"k1", time.Hour}, {"k2", JWKSMaxAge - time.Minute}}, "k1"},
+		{"新密钥发布满 JWKSMaxAge 后切换", []key{{"k1", time.Hour}, {"k2", JWKSMaxAge + time.Second}}, "k2"},
+		{"按 kid 而不是文件时间取最新", []key{{"k1", time.Minute * 10}, {"k2", time.Hour}}, "k2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, k := range tt.keys {
+				writeKey(t, dir, k.kid, AlgEdDSA, k.age)
+			}
+			ks, err := LoadKeySet(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ks.Active()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("Active() = %s, want %s", got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySetEmpty(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Active(); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Active() error = %v, want ErrNoSigningKey", err)
+	}
+	if _, err := LoadKeySet(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing key dir: %v", err)
+	}
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "k1", AlgRS256, time.Hour)
+	writeKey(t, dir, "k2", AlgEdDSA, 0)
+	ks, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := ks.sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := jwt.Parse(signed, ks.keyFunc)
+	if err != nil {
+		t.Fatalf("parse signed token: %v", err)
+	}
+	if kid := tok.Header["kid"]; kid != "k1" {
+		t.Errorf("signed with kid %v, want k1", kid)
+	}
+
+	// 换用其他算法签名的令牌即使 kid 正确也不接受
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "1"})
+	forged.Header["kid"] = "k1"
+	s, err := forged.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(s, ks.keyFunc); err == nil {
+		t.Error("token with mismatched alg accepted")
+	}
+
+	var kids []string
+	for _, k := range ks.JWKS()["keys"].([]map[string]string) {
+		kids = append(kids, k["kid"])
+	}
+	if !reflect.DeepEqual(kids, []string{"k2", "k1"}) {
+		t.Errorf("JWKS kids = %v, want [k2 k1]", kids)
+	}
+}
+
+func TestPruneKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, kid := range []string{"k1", "k2", "k3"} {
+		writeKey(t, dir, kid, AlgEdDSA, time.Hour)
+	}
+	removed, err := PruneKeyFiles(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"k1"}) {
+		t.Errorf("removed %v, want [k1]", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "k2.pem")); err != nil {
+		t.Errorf("k2 should be kept: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+)
+
+var ErrOneTimeTokenInvalid = errors.New("invalid or expired token")
+
+// IssueOneTimeToken 为用户签发一次性令牌（找回密码、验证邮箱等），同用途的旧令牌随之作废
+func IssueOneTimeToken(db *gorm.DB, uid uint, purpose string, ttl time.Duration) (string, error) {
+	raw, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&repo.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", uid, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&repo.UserToken{
+			UserID:    uid,
+			Purpose:   purpose,
+			TokenHash: HashToken(raw),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ConsumeOneTimeToken 原子地核销一次性令牌并返回所属用户ID
+func ConsumeOneTimeToken(db *gorm.DB, raw, purpose string) (uint, error) {
+	var t repo.UserToken
+	now := time.Now()
+	res := db.Model(&t).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}}}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", HashToken(raw), purpose, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, ErrOneTimeTokenInvalid
+	}
+	return t.UserID, nil
+}
+
+// PruneOneTimeTokens 删除已过期的一次性令牌
+func PruneOneTimeTokens(db *gorm.DB) (int64, error) {
+	res := db.Where("expires_at < ?", time.Now()).Delete(&repo.UserToken{})
+	return res.RowsAffected, res.Error
+}
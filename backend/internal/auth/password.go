@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// PasswordPolicy 描述密码强度要求
+type PasswordPolicy struct {
+	MinLength    int  // 最少字符数（按 Unicode 字符计）
+	RejectCommon bool // 拒绝常见弱密码
+}
+
+// PasswordError 是不满足密码策略时返回的错误，消息可直接展示给用户
+type PasswordError struct {
+	Reason string
+}
+
+func (e *PasswordError) Error() string { return e.Reason }
+
+var (
+	policyMu sync.RWMutex
+	policy   = PasswordPolicy{MinLength: 8, RejectCommon: true}
+
+	commonOnce      sync.Once
+	commonPasswords map[string]struct{}
+)
+
+// SetPasswordPolicy 设置全局密码策略
+func SetPasswordPolicy(p PasswordPolicy) {
+	policyMu.Lock()
+	policy = p
+	policyMu.Unlock()
+}
+
+// CurrentPasswordPolicy 返回当前密码策略
+func CurrentPasswordPolicy() PasswordPolicy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policy
+}
+
+// ValidatePassword 按当前策略校验密码；related 为用户邮箱、昵称等，密码不得与之相同
+func ValidatePassword(password string, related ...string) error {
+	p := CurrentPasswordPolicy()
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &PasswordError{Reason: fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+	if len(password) > 72 {
+		// bcrypt 只使用前 72 字节
+		return &PasswordError{Reason: "password must be at most 72 bytes"}
+	}
+	lower := strings.ToLower(password)
+	for _, r := range related {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if r == "" {
+			continue
+		}
+		if lower == r || (strings.Contains(r, "@") && lower == strings.SplitN(r, "@", 2)[0]) {
+			return &PasswordError{Reason: "password must not match your email or name"}
+		}
+	}
+	if p.RejectCommon && isCommonPassword(lower) {
+		return &PasswordError{Reason: "password is too common"}
+	}
+	return nil
+}
+
+func isCommonPassword(lower string) bool {
+	commonOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		sc := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			commonPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	})
+	_, ok := commonPasswords[lower]
+	return ok
+}
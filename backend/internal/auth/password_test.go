@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		related  []string
+		wantErr  bool
+	}{
+		{"满足策略", "correct-horse-battery", nil, false},
+		{"太短", "abc123!", nil, true},
+		{"按字符而不是字节计长度", "密码密码密码密码", nil, false},
+		{"超过 72 字节", strings.Repeat("密", 25), nil, true},
+		{"常见弱密码", "password", nil, true},
+		{"常见弱密码忽略大小写", "PassWord", nil, true},
+		{"与邮箱相同", "Alice@Example.com", []string{"alice@example.com"}, true},
+		{"与邮箱用户名相同", "alice.smith", []string{"Alice.Smith@example.com"}, true},
+		{"与昵称相同", "Maimang2024", []string{"alice@example.com", " maimang2024 "}, true},
+		{"只是包含昵称", "maimang2024-rocks", []string{"maimang2024"}, false},
+		{"空的关联信息不影响", "correct-horse-battery", []string{"", "  "}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePassword(tt.password, tt.related...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePassword(%q) = %v, wantErr %v", tt.password, err, tt.wantErr)
+			}
+			if _, ok := err.(*PasswordError); err != nil && !ok {
+				t.Errorf("error %T is not *PasswordError", err)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordPolicy(t *testing.T) {
+	defer SetPasswordPolicy(CurrentPasswordPolicy())
+
+	SetPasswordPolicy(PasswordPolicy{MinLength: 12})
+	if err := ValidatePassword("short-pass1"); err == nil {
+		t.Error("11 characters accepted with MinLength 12")
+	}
+	SetPasswordPolicy(PasswordPolicy{MinLength: 8})
+	if err := ValidatePassword("password"); err != nil {
+		t.Errorf("common password rejected with RejectCommon off: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+)
+
+var (
+	ErrRefreshInvalid = errors.New("invalid refresh token")
+	ErrRefreshReused  = errors.New("refresh token reused")
+)
+
+// HashToken 返回令牌的 SHA-256 十六进制摘要，数据库中只保存摘要
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomToken 生成 n 字节随机数的 URL 安全编码
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IssueRefreshToken 为用户签发一个新的刷新令牌；familyID 为空时开启新的令牌家族
+func IssueRefreshToken(db *gorm.DB, uid uint, familyID string, ttl time.Duration) (string, *repo.RefreshToken, error) {
+	raw, err := RandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	if familyID == "" {
+		familyID = uuid.NewString()
+	}
+	rt := &repo.RefreshToken{
+		UserID:    uid,
+		FamilyID:  familyID,
+		TokenHash: HashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := db.Create(rt).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, rt, nil
+}
+
+// RotateRefreshToken 用旧令牌换取同一家族的新令牌。
+// 已轮换或已吊销的令牌再次出现时视为泄露，整个家族会被吊销并返回 ErrRefreshReused。
+func RotateRefreshToken(db *gorm.DB, raw string, ttl time.Duration) (string, *repo.RefreshToken, error) {
+	var (
+		newRaw string
+		newRT  *repo.RefreshToken
+		reused string
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var old repo.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(raw)).
+			First(&old).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshInvalid
+			}
+			return err
+		}
+		if old.UsedAt != nil || old.RevokedAt != nil {
+			reused = old.FamilyID
+			return ErrRefreshReused
+		}
+		now := time.Now()
+		if now.After(old.ExpiresAt) {
+			return ErrRefreshInvalid
+		}
+		if err := tx.Model(&old).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		var err error
+		newRaw, newRT, err = IssueRefreshToken(tx, old.UserID, old.FamilyID, ttl)
+		return err
+	})
+	if errors.Is(err, ErrRefreshReused) && reused != "" {
+		if rerr := RevokeRefreshFamily(db, reused); rerr != nil {
+			return "", nil, rerr
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return newRaw, newRT, nil
+}
+
+// LookupRefreshToken 按原始令牌查找记录（不校验有效性）
+func LookupRefreshToken(db *gorm.DB, raw string) (*repo.RefreshToken, error) {
+	var rt repo.RefreshToken
+	if err := db.Where("token_hash = ?", HashToken(raw)).First(&rt).Error; err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// RevokeRefreshFamily 吊销某个家族中所有尚未吊销的令牌，并结束对应的登录会话
+func RevokeRefreshFamily(db *gorm.DB, familyID string) error {
+	now := time.Now()
+	if err := db.Model(&repo.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	err := db.Model(&repo.Session{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+	invalidateSessions(0, familyID)
+	return err
+}
+
+// RevokeUserRefreshTokens 吊销用户的全部刷新令牌，并结束其全部登录会话
+func RevokeUserRefreshTokens(db *gorm.DB, uid uint) error {
+	now := time.Now()
+	if err := db.Model(&repo.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	err := db.Model(&repo.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", now).Error
+	invalidateSessions(uid, "")
+	return err
+}
+
+// PruneRefreshTokens 删除已过期的刷新令牌，返回删除的行数
+func PruneRefreshTokens(db *gorm.DB) (int64, error) {
+	res := db.Where("expires_at < ?", time.Now()).Delete(&repo.RefreshToken{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/testdb"
+)
+
+func openRefreshDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testdb.Open(t, &repo.User{}, &repo.Session{}, &repo.RefreshToken{})
+}
+
+// issueSession 模拟一次登录：创建会话和该家族的第一个刷新令牌
+func issueSession(t *testing.T, db *gorm.DB, uid uint) (string, string) {
+	t.Helper()
+	raw, rt, err := IssueRefreshToken(db, uid, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := repo.Session{ID: rt.FamilyID, UserID: uid, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&s).Error; err != nil {
+		t.Fatal(err)
+	}
+	return raw, rt.FamilyID
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := openRefreshDB(t)
+	first, family := issueSession(t, db, 1)
+
+	second, rt, err := RotateRefreshToken(db, first, time.Hour)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rt.FamilyID != family || second == first {
+		t.Errorf("rotated token family = %s, want %s", rt.FamilyID, family)
+	}
+	old, err := LookupRefreshToken(db, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.UsedAt == nil || old.RevokedAt != nil {
+		t.Errorf("old token used_at = %v, revoked_at = %v", old.UsedAt, old.RevokedAt)
+	}
+
+	// 新令牌可以继续轮换
+	if _, _, err := RotateRefreshToken(db, second, time.Hour); err != nil {
+		t.Errorf("rotate second token: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenInvalid(t *testing.T) {
+	db := openRefreshDB(t)
+	expired, _, err := IssueRefreshToken(db, 1, "", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"不存在的令牌", "no-such-token"},
+		{"已过期的令牌", expired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := RotateRefreshToken(db, tt.raw, time.Hour); !errors.Is(err, ErrRefreshInvalid) {
+				t.Errorf("rotate error = %v, want ErrRefreshInvalid", err)
+			}
+		})
+	}
+}
+
+// 已轮换的令牌再次出现时吊销整个家族和对应会话，其他家族不受影响
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	db := openRefreshDB(t)
+	first, family := issueSession(t, db, 1)
+	other, otherFamily := issueSession(t, db, 1)
+
+	second, _, err := RotateRefreshToken(db, first, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RotateRefreshToken(db, first, time.Hour); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("reuse error = %v, want ErrRefreshReused", err)
+	}
+
+	var active int64
+	db.Model(&repo.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", family).Count(&active)
+	if active != 0 {
+		t.Errorf("%d tokens in reused family still active", active)
+	}
+	if _, _, err := RotateRefreshToken(db, second, time.Hour); !errors.Is(err, ErrRefreshReused) {
+		t.Errorf("rotate token from revoked family: error = %v, want ErrRefreshReused", err)
+	}
+
+	var s repo.Session
+	if err := db.First(&s, "id = ?", family).Error; err != nil {
+		t.Fatal(err)
+	}
+	if s.RevokedAt == nil {
+		t.Error("session of reused family not revoked")
+	}
+	if err := db.First(&s, "id = ?", otherFamily).Error; err != nil {
+		t.Fatal(err)
+	}
+	if s.RevokedAt != nil {
+		t.Error("session of other family revoked")
+	}
+	if _, _, err := RotateRefreshToken(db, other, time.Hour); err != nil {
+		t.Errorf("rotate token from other family: %v", err)
+	}
+}
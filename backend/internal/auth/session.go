@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// StartSession 为一次登录创建会话，返回的会话 ID 作为刷新令牌的 FamilyID
+func StartSession(db *gorm.DB, uid uint, userAgent, ip string, ttl time.Duration) (*repo.Session, error) {
+	now := time.Now()
+	s := &repo.Session{
+		ID:         uuid.NewString(),
+		UserID:     uid,
+		Device:     deviceLabel(userAgent),
+		UserAgent:  truncate(userAgent, 500),
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if err := db.Create(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ExtendSession 刷新令牌轮换时延长会话；旧版本签发、尚无会话记录的家族会补建会话
+func ExtendSession(db *gorm.DB, sid string, uid uint, userAgent, ip string, expiresAt time.Time) error {
+	now := time.Now()
+	s := &repo.Session{
+		ID:         sid,
+		UserID:     uid,
+		Device:     deviceLabel(userAgent),
+		UserAgent:  truncate(userAgent, 500),
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ip", "last_seen_at", "expires_at"}),
+	}).Create(s).Error
+}
+
+// RevokeSession 结束用户的某个会话：吊销其刷新令牌，已签发的访问令牌随即失效
+func RevokeSession(db *gorm.DB, uid uint, sid string) error {
+	var count int64
+	if err := db.Model(&repo.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sid, uid).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return RevokeRefreshFamily(db, sid)
+}
+
+// ListSessions 返回用户当前有效的会话，最近活跃的在前
+func ListSessions(db *gorm.DB, uid uint) ([]repo.Session, error) {
+	var sessions []repo.Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", uid, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// PruneSessions 删除已过期或已结束超过 30 天的会话
+func PruneSessions(db *gorm.DB) (int64, error) {
+	now := time.Now()
+	res := db.Where("expires_at < ? OR revoked_at < ?", now, now.AddDate(0, 0, -30)).Delete(&repo.Session{})
+	return res.RowsAffected, res.Error
+}
+
+type sessionEntry struct {
+	uid     uint
+	active  bool
+	expires time.Time
+}
+
+// sessionStates 缓存会话是否有效，TTL 与用户状态缓存一致；本进程内的吊销立即生效
+var sessionStates = struct {
+	sync.RWMutex
+	entries map[string]sessionEntry
+}{entries: make(map[string]sessionEntry)}
+
+// sessionActive 判断访问令牌所属会话是否仍然有效
+func sessionActive(db *gorm.DB, sid string, uid uint) (bool, error) {
+	sessionStates.RLock()
+	e, ok := sessionStates.entries[sid]
+	sessionStates.RUnlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.active && e.uid == uid, nil
+	}
+
+	var s repo.Session
+	err := db.Select("id", "user_id", "expires_at", "revoked_at").Where("id = ?", sid).First(&s).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	active := err == nil && s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+
+	userStates.mu.RLock()
+	ttl := userStates.ttl
+	userStates.mu.RUnlock()
+	if ttl > 0 {
+		sessionStates.Lock()
+		sessionStates.entries[sid] = sessionEntry{uid: s.UserID, active: active, expires: time.Now().Add(ttl)}
+		sessionStates.Unlock()
+	}
+	return active && s.UserID == uid, nil
+}
+
+// invalidateSessions 清除会话缓存；sid 为空时清除该用户的全部会话
+func invalidateSessions(uid uint, sid string) {
+	sessionStates.Lock()
+	defer sessionStates.Unlock()
+	if sid != "" {
+		delete(sessionStates.entries, sid)
+		return
+	}
+	for id, e := range sessionStates.entries {
+		if e.uid == uid {
+			delete(sessionStates.entries, id)
+		}
+	}
+}
+
+// 最近活跃时间最多每分钟写一次库
+const sessionTouchInterval = time.Minute
+
+var sessionTouches sync.Map // sid -> time.Time
+
+// TouchSession 记录会话最近活跃时间与 IP
+func TouchSession(db *gorm.DB, sid, ip string) {
+	now := time.Now()
+	if last, ok := sessionTouches.Load(sid); ok && now.Sub(last.(time.Time)) < sessionTouchInterval {
+		return
+	}
+	sessionTouches.Store(sid, now)
+	db.Model(&repo.Session{}).Where("id = ?", sid).
+		UpdateColumns(map[string]interface{}{"last_seen_at": now, "ip": ip})
+}
+
+// deviceLabel 从 User-Agent 粗略识别浏览器和系统，如 "Chrome / Windows"
+func deviceLabel(ua string) string {
+	if ua == "" {
+		return "未知设备"
+	}
+	browser := "未知浏览器"
+	for _, b := range []struct{ token, name string }{
+		{"MicroMessenger", "微信"},
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"python-requests", "Python"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+	if os == "" {
+		return browser
+	}
+	return browser + " / " + os
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
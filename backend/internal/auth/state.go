@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+)
+
+var (
+	ErrUserDisabled = errors.New("user disabled")
+	ErrTokenRevoked = errors.New("token revoked")
+)
+
+// UserState 是鉴权时需要的用户实时状态
+type UserState struct {
+	Role         string
+	Status       string
+	TokenVersion int
+	TOTPEnabled  bool
+}
+
+type stateEntry struct {
+	state   UserState
+	expires time.Time
+}
+
+// stateCache 进程内缓存用户状态，避免每个请求都查询数据库。
+// 本进程内的修改通过 InvalidateUserState 立即生效；其他实例最多延迟 ttl。
+type stateCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[uint]stateEntry
+}
+
+var userStates = &stateCache{ttl: 30 * time.Second, entries: make(map[uint]stateEntry)}
+
+// SetStateCacheTTL 设置用户状态缓存时间，0 表示不缓存
+func SetStateCacheTTL(ttl time.Duration) {
+	userStates.mu.Lock()
+	defer userStates.mu.Unlock()
+	userStates.ttl = ttl
+	userStates.entries = make(map[uint]stateEntry)
+	sessionStates.Lock()
+	sessionStates.entries = make(map[string]sessionEntry)
+	sessionStates.Unlock()
+}
+
+// LoadUserState 读取用户状态，优先使用缓存
+func LoadUserState(db *gorm.DB, uid uint) (UserState, error) {
+	userStates.mu.RLock()
+	e, ok := userStates.entries[uid]
+	userStates.mu.RUnlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.state, nil
+	}
+
+	var user repo.User
+	if err := db.Select("id", "role", "status", "token_version", "totp_enabled").First(&user, uid).Error; err != nil {
+		return UserState{}, err
+	}
+	state := UserState{
+		Role:         string(user.Role),
+		Status:       user.Status,
+		TokenVersion: user.TokenVersion,
+		TOTPEnabled:  user.TOTPEnabled,
+	}
+
+	userStates.mu.Lock()
+	if userStates.ttl > 0 {
+		userStates.entries[uid] = stateEntry{state: state, expires: time.Now().Add(userStates.ttl)}
+	}
+	userStates.mu.Unlock()
+	return state, nil
+}
+
+// InvalidateUserState 清除用户的缓存状态，角色或状态变更后调用
+func InvalidateUserState(uid uint) {
+	userStates.mu.Lock()
+	delete(userStates.entries, uid)
+	userStates.mu.Unlock()
+}
+
+// BumpTokenVersion 递增用户令牌版本，使其已签发的访问令牌全部失效
+func BumpTokenVersion(db *gorm.DB, uid uint) error {
+	err := db.Model(&repo.User{}).Where("id = ?", uid).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+	InvalidateUserState(uid)
+	return err
+}
+
+// ValidateClaims 用数据库中的实时状态校验访问令牌，返回当前有效的用户状态
+func ValidateClaims(db *gorm.DB, claims *Claims) (UserState, error) {
+	state, err := LoadUserState(db, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return UserState{}, ErrTokenRevoked
+		}
+		return UserState{}, err
+	}
+	if state.Status != "active" {
+		return UserState{}, ErrUserDisabled
+	}
+	if state.TokenVersion != claims.Version {
+		return UserState{}, ErrTokenRevoked
+	}
+	if claims.SessionID != "" {
+		active, err := sessionActive(db, claims.SessionID, claims.UserID)
+		if err != nil {
+			return UserState{}, err
+		}
+		if !active {
+			return UserState{}, ErrTokenRevoked
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 缓存命中且令牌不带会话 ID 时不访问数据库，db 传 nil 即可
+func TestValidateClaims(t *testing.T) {
+	const uid = 4242
+	userStates.mu.Lock()
+	userStates.entries[uid] = stateEntry{
+		state:   UserState{Role: "member", Status: "active", TokenVersion: 3},
+		expires: time.Now().Add(time.Minute),
+	}
+	userStates.entries[uid+1] = stateEntry{
+		state:   UserState{Role: "member", Status: "banned", TokenVersion: 3},
+		expires: time.Now().Add(time.Minute),
+	}
+	userStates.mu.Unlock()
+	defer InvalidateUserState(uid)
+	defer InvalidateUserState(uid + 1)
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		wantErr error
+	}{
+		{"状态正常且版本一致", Claims{UserID: uid, Role: "admin", Version: 3}, nil},
+		{"旧版本的令牌", Claims{UserID: uid, Version: 2}, ErrTokenRevoked},
+		{"已封禁的用户", Claims{UserID: uid + 1, Version: 3}, ErrUserDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := ValidateClaims(nil, &tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateClaims error = %v, want %v", err, tt.wantErr)
+			}
+			// 以数据库中的角色为准，不信任令牌中的角色
+			if err == nil && state.Role != "member" {
+				t.Errorf("role = %s, want member", state.Role)
+			}
+		})
+	}
+}
+
+func TestParseTokenKinds(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "k1", AlgEdDSA, time.Hour)
+	ks, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetKeySet(CurrentKeySet())
+	SetKeySet(ks)
+
+	access, err := GenerateAccessToken(7, "member", 2, "sid", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, err := GenerateMFAToken(7, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := GenerateAccessToken(7, "member", 2, "", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseToken(access)
+	if err != nil {
+		t.Fatalf("ParseToken(access): %v", err)
+	}
+	if claims.UserID != 7 || claims.Version != 2 || claims.SessionID != "sid" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := ParseToken(mfa); err == nil {
+		t.Error("MFA token accepted as access token")
+	}
+	if _, err := ParseMFAToken(access); err == nil {
+		t.Error("access token accepted as MFA token")
+	}
+	if _, err := ParseMFAToken(mfa); err != nil {
+		t.Errorf("ParseMFAToken(mfa): %v", err)
+	}
+	if _, err := ParseToken(expired); err == nil {
+		t.Error("expired token accepted")
+	}
+}
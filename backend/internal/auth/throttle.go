@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+)
+
+// ThrottlePolicy 登录防暴力破解策略
+type ThrottlePolicy struct {
+	EmailMaxFailures int           // 同一邮箱连续失败多少次后锁定
+	IPMaxFailures    int           // 同一 IP 连续失败多少次后锁定（校园网常共用出口，应更宽松）
+	Lockout          time.Duration // 锁定时长
+	Window           time.Duration // 超过该时长没有新的失败则重新计数
+	BackoffFree      int           // 前几次失败不需要等待
+	BackoffBase      time.Duration // 指数退避基数
+	BackoffMax       time.Duration // 指数退避上限
+}
+
+var (
+	throttleMu sync.RWMutex
+	throttle   = ThrottlePolicy{
+		EmailMaxFailures: 5,
+		IPMaxFailures:    20,
+		Lockout:          15 * time.Minute,
+		Window:           15 * time.Minute,
+		BackoffFree:      2,
+		BackoffBase:      time.Second,
+		BackoffMax:       time.Minute,
+	}
+)
+
+// SetThrottlePolicy 设置登录防暴力破解策略
+func SetThrottlePolicy(p ThrottlePolicy) {
+	throttleMu.Lock()
+	throttle = p
+	throttleMu.Unlock()
+}
+
+func currentThrottlePolicy() ThrottlePolicy {
+	throttleMu.RLock()
+	defer throttleMu.RUnlock()
+	return throttle
+}
+
+func emailKey(email string) string { return "email:" + strings.ToLower(strings.TrimSpace(email)) }
+func ipKey(ip string) string       { return "ip:" + ip }
+
+// backoff 返回第 failures 次失败后需要等待的时长
+func (p ThrottlePolicy) backoff(failures int) time.Duration {
+	n := failures - p.BackoffFree
+	if n <= 0 {
+		return 0
+	}
+	d := p.BackoffBase
+	for i := 1; i < n && d < p.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > p.BackoffMax {
+		d = p.BackoffMax
+	}
+	return d
+}
+
+// CheckLoginThrottle 返回本次登录尝试还需等待的时长，0 表示可以尝试
+func CheckLoginThrottle(db *gorm.DB, email, ip string) (time.Duration, error) {
+	p := currentThrottlePolicy()
+	var rows []repo.LoginThrottle
+	if err := db.Where("key IN ?", []string{emailKey(email), ipKey(ip)}).Find(&rows).Error; err != nil {
+		return 0, err
+	}
+	return p.wait(rows, time.Now()), nil
+}
+
+// wait 按失败计数计算 now 时还需等待的时长：锁定中等到解锁，否则按最近一次失败退避
+func (p ThrottlePolicy) wait(rows []repo.LoginThrottle, now time.Time) time.Duration {
+	var wait time.Duration
+	for _, r := range rows {
+		if r.LockedUntil != nil && now.Before(*r.LockedUntil) {
+			if d := r.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+			continue
+		}
+		if now.Sub(r.LastFailureAt) > p.Window {
+			continue
+		}
+		if d := r.LastFailureAt.Add(p.backoff(r.Failures)).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// RecordLoginFailure 记录一次失败的登录；达到阈值时锁定并写入审计记录，返回是否触发了新的锁定
+func RecordLoginFailure(db *gorm.DB, email, ip string) (bool, error) {
+	p := currentThrottlePolicy()
+	locked := false
+	for _, k := range []struct {
+		key string
+		max int
+	}{{emailKey(email), p.EmailMaxFailures}, {ipKey(ip), p.IPMaxFailures}} {
+		l, err := recordFailure(db, p, k.key, k.max, email, ip)
+		if err != nil {
+			return locked, err
+		}
+		locked = locked || l
+	}
+	return locked, nil
+}
+
+func recordFailure(db *gorm.DB, p ThrottlePolicy, key string, max int, email, ip string) (bool, error) {
+	now := time.Now()
+	var row repo.LoginThrottle
+	// 原子地累加失败次数；超过计数窗口则从 1 重新开始
+	if err := db.Raw(`
+		INSERT INTO login_throttles (key, failures, last_failure_at, created_at, updated_at)
+		VALUES (?, 1, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, key, failures, last_failure_at, locked_until`,
+		key, now, now, now, now.Add(-p.Window)).Scan(&row).Error; err != nil {
+		return false, err
+	}
+	if max <= 0 || row.Failures < max {
+		return false, nil
+	}
+
+	// 只有从未锁定或锁定已过期时才重新锁定，避免并发请求重复记录
+	until := now.Add(p.Lockout)
+	res := db.Model(&repo.LoginThrottle{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", row.ID, now).
+		Update("locked_until", until)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	event := repo.LockoutEvent{
+		Key:         key,
+		Email:       strings.ToLower(strings.TrimSpace(email)),
+		IP:          ip,
+		Failures:    row.Failures,
+		LockedUntil: until,
+	}
+	return true, db.Create(&event).Error
+}
+
+// RecordLoginSuccess 登录成功后清除该邮箱的失败计数（IP 计数保留，防止用一个有效账号为撞库解锁）
+func RecordLoginSuccess(db *gorm.DB, email string) error {
+	return db.Where("key = ?", emailKey(email)).Delete(&repo.LoginThrottle{}).Error
+}
+
+// ClearLockout 管理员解除锁定，并在审计记录中标记处理人
+func ClearLockout(db *gorm.DB, throttleID, adminID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var row repo.LoginThrottle
+		if err := tx.First(&row, throttleID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&repo.LockoutEvent{}).
+			Where("key = ? AND cleared_at IS NULL AND locked_until > ?", row.Key, now).
+			Updates(map[string]interface{}{"cleared_by": adminID, "cleared_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&row).Error
+	})
+}
+
+// PruneLoginThrottles 删除计数窗口和锁定都已过期的失败计数（审计记录保留）
+func PruneLoginThrottles(db *gorm.DB) (int64, error) {
+	p := currentThrottlePolicy()
+	now := time.Now()
+	res := db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-p.Window), now).
+		Delete(&repo.LoginThrottle{})
+	return res.RowsAffected, res.Error
+}
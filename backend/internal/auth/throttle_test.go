@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"maimang/backend/internal/repo"
+)
+
+var testPolicy = ThrottlePolicy{
+	EmailMaxFailures: 5,
+	IPMaxFailures:    20,
+	Lockout:          15 * time.Minute,
+	Window:           15 * time.Minute,
+	BackoffFree:      2,
+	BackoffBase:      time.Second,
+	BackoffMax:       time.Minute,
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{8, 32 * time.Second},
+		{9, time.Minute}, // 64 秒超过上限
+		{1000, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := testPolicy.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	tests := []struct {
+		name string
+		rows []repo.LoginThrottle
+		want time.Duration
+	}{
+		{"没有失败记录", nil, 0},
+		{"免等待的失败次数", []repo.LoginThrottle{{Failures: 2, LastFailureAt: now}}, 0},
+		{"退避尚未结束", []repo.LoginThrottle{{Failures: 4, LastFailureAt: now.Add(-time.Second)}}, time.Second},
+		{"退避已经结束", []repo.LoginThrottle{{Failures: 4, LastFailureAt: now.Add(-3 * time.Second)}}, 0},
+		{"锁定中等到解锁", []repo.LoginThrottle{{Failures: 5, LastFailureAt: now, LockedUntil: at(10 * time.Minute)}}, 10 * time.Minute},
+		{"锁定过期后按退避计算", []repo.LoginThrottle{{Failures: 9, LastFailureAt: now.Add(-30 * time.Second), LockedUntil: at(-time.Second)}}, 30 * time.Second},
+		{"超过计数窗口不再等待", []repo.LoginThrottle{{Failures: 100, LastFailureAt: now.Add(-16 * time.Minute)}}, 0},
+		{"邮箱和 IP 取较长的等待", []repo.LoginThrottle{
+			{Key: "email:a@b.c", Failures: 3, LastFailureAt: now},
+			{Key: "ip:1.2.3.4", Failures: 5, LastFailureAt: now},
+		}, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testPolicy.wait(tt.rows, now); got != tt.want {
+				t.Errorf("wait = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleEmailKey(t *testing.T) {
+	if got, want := emailKey("  Alice@Example.COM "), "email:alice@example.com"; got != want {
+		t.Errorf("emailKey = %q, want %q", got, want)
+	}
+}
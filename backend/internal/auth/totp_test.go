@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试密钥 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPAtRFCVectors(t *testing.T) {
+	key, err := b32.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 附录 B 给出 8 位验证码，这里取末 6 位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpAt(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpAt(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	key, _ := b32.DecodeString(rfcSecret)
+	codeAt := func(offset int64) string { return totpAt(key, step+offset) }
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"当前步长", rfcSecret, "050471", step, true},
+		{"上一个步长在偏差内", rfcSecret, codeAt(-1), step - 1, true},
+		{"下一个步长在偏差内", rfcSecret, codeAt(1), step + 1, true},
+		{"超出偏差的旧验证码", rfcSecret, codeAt(-2), 0, false},
+		{"超出偏差的新验证码", rfcSecret, codeAt(2), 0, false},
+		{"验证码带空格", rfcSecret, " 050 471 ", step, true},
+		{"密钥小写", strings.ToLower(rfcSecret), "050471", step, true},
+		{"位数不对", rfcSecret, "50471", 0, false},
+		{"错误的验证码", rfcSecret, "000000", 0, false},
+		{"密钥不是 Base32", "not-base32!", "050471", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := VerifyTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("VerifyTOTP(%q) = %d, %v, want %d, %v", tt.code, got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// 同一验证码在下一个步长内仍然有效，但返回的是它原本的步长，
+// 调用方据此与 totp_last_step 比较即可拒绝重放
+func TestVerifyTOTPReplayStep(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	first, ok := VerifyTOTP(rfcSecret, "050471", now)
+	if !ok {
+		t.Fatal("first verification failed")
+	}
+	again, ok := VerifyTOTP(rfcSecret, "050471", now.Add(totpPeriod*time.Second))
+	if !ok {
+		t.Fatal("code should still be accepted within skew")
+	}
+	if again != first {
+		t.Errorf("replayed code matched step %d, want %d", again, first)
+	}
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer 把邮件写入本地目录并打印日志，用于开发和测试环境
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return fmt.Errorf("create mail dir: %w", err)
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405"), m.seq)
+	m.mu.Unlock()
+
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, render(m.From, msg), 0644); err != nil {
+		return fmt.Errorf("write mail: %w", err)
+	}
+	log.Printf("Mail to %s (%s) written to %s", strings.Join(msg.To, ", "), msg.Subject, path)
+	return nil
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := NewFileMailer(dir, "noreply@example.com")
+	msg := Message{To: []string{"a@example.com", "b@example.com"}, Subject: "麦芒文学社 - 验证邮箱", Body: "第一行\n第二行\n"}
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("wrote %d files, want 2", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("parse written mail: %v", err)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[1].Address != "b@example.com" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q, %v, want %q", subject, err, msg.Subject)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	if got, want := string(body), "第一行\r\n第二行\r\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
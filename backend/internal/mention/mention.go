@@ -0,0 +1,190 @@
+// Package mention 解析内容中的 @提及 并保存提及记录。
+package mention
+
+import (
+	"log"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+)
+
+// 提及目标类型
+const (
+	TargetComment = "comment"
+	TargetWork    = "work"
+)
+
+const (
+	maxNameLength = 30 // @ 之后最多取多少个字符去匹配用户名
+	maxMentions   = 20 // 一段内容最多记录的提及数
+	// 最多查询多少个候选；每个候选最多展开为 maxNameLength 个前缀，须远低于数据库的参数个数上限
+	maxCandidates = maxMentions * 2
+)
+
+// Span 内容中的一处提及；Start/End 按 UTF-16 码元计，与前端 JS 字符串下标一致
+type Span struct {
+	UserID uint
+	Start  int
+	End    int
+}
+
+// candidate @ 之后的一段文字，name 为其中可能是用户名的部分
+type candidate struct {
+	start int
+	name  []rune
+}
+
+// runeLen 字符占用的 UTF-16 码元数
+func runeLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// isNameRune 用户名可包含的字符；遇到空白或标点即结束
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_' || r == '-' || r == '·'
+}
+
+// parse 找出内容中 @ 开头的候选，最多 maxCandidates 个；@ 紧跟在英文字母数字之后（如邮箱）不算提及，
+// 紧跟在中文等其他文字之后仍算
+func parse(text string) []candidate {
+	var out []candidate
+	var cur *candidate
+	var prev rune
+	offset := 0
+	for _, r := range text {
+		if cur != nil {
+			if isNameRune(r) && len(cur.name) < maxNameLength {
+				cur.name = append(cur.name, r)
+			} else {
+				cur = nil
+			}
+		}
+		if cur == nil && r == '@' && !(prev < utf8.RuneSelf && (unicode.IsLetter(prev) || unicode.IsDigit(prev))) {
+			out = append(out, candidate{start: offset})
+			cur = &out[len(out)-1]
+		}
+		offset += runeLen(r)
+		prev = r
+	}
+	// 去掉 @ 之后没有名字的候选
+	kept := out[:0]
+	for _, c := range out {
+		if len(c.name) > 0 && len(kept) < maxCandidates {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// Resolve 把内容中的提及解析为用户。用户名之后可能紧跟正文（如"@张小明这句写得好"），
+// 因此取能匹配到用户的最长前缀；重名用户无法区分，不记录。已封禁的用户不可被提及
+func Resolve(db *gorm.DB, text string) ([]Span, error) {
+	cands := parse(text)
+	list := prefixes(cands)
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	var users []repo.User
+	if err := db.Select("id", "name").
+		Where("name IN ? AND status <> ?", list, "banned").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byName := map[string][]uint{}
+	for _, u := range users {
+		byName[u.Name] = append(byName[u.Name], u.ID)
+	}
+	return match(cands, byName), nil
+}
+
+// prefixes 返回候选中所有可能是用户名的前缀（去重）
+func prefixes(cands []candidate) []string {
+	seen := map[string]bool{}
+	var list []string
+	for _, c := range cands {
+		for i := 1; i <= len(c.name); i++ {
+			if p := string(c.name[:i]); !seen[p] {
+				seen[p] = true
+				list = append(list, p)
+			}
+		}
+	}
+	return list
+}
+
+// match 按用户名对应的用户 ID 为每个候选取最长的唯一匹配
+func match(cands []candidate, byName map[string][]uint) []Span {
+	var spans []Span
+	seen := map[uint]bool{}
+	for _, c := range cands {
+		for i := len(c.name); i > 0; i-- {
+			ids := byName[string(c.name[:i])]
+			if len(ids) == 0 {
+				continue
+			}
+			if len(ids) > 1 || (!seen[ids[0]] && len(seen) >= maxMentions) {
+				break
+			}
+			seen[ids[0]] = true
+			end := c.start + 1 // @ 本身
+			for _, r := range c.name[:i] {
+				end += runeLen(r)
+			}
+			spans = append(spans, Span{UserID: ids[0], Start: c.start, End: end})
+			break
+		}
+	}
+	return spans
+}
+
+// Sync 重新解析内容并替换目标的提及记录；已通知过的用户保留通知时间，编辑后不重复通知。
+// 失败只记录日志，不影响保存内容
+func Sync(db *gorm.DB, targetType string, targetID, authorID uint, text string) {
+	spans, err := Resolve(db, text)
+	if err != nil {
+		log.Printf("Resolve mentions error: %v", err)
+		return
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var old []repo.Mention
+		if err := tx.Where("target_type = ? AND target_id = ? AND notified_at IS NOT NULL", targetType, targetID).
+			Find(&old).Error; err != nil {
+			return err
+		}
+		notified := map[uint]*time.Time{}
+		for _, m := range old {
+			notified[m.UserID] = m.NotifiedAt
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", targetType, targetID).
+			Delete(&repo.Mention{}).Error; err != nil {
+			return err
+		}
+		if len(spans) == 0 {
+			return nil
+		}
+		list := make([]repo.Mention, 0, len(spans))
+		for _, s := range spans {
+			list = append(list, repo.Mention{
+				TargetType: targetType,
+				TargetID:   targetID,
+				AuthorID:   authorID,
+				UserID:     s.UserID,
+				Start:      s.Start,
+				End:        s.End,
+				NotifiedAt: notified[s.UserID],
+			})
+		}
+		return tx.Create(&list).Error
+	})
+	if err != nil {
+		log.Printf("Save mentions error: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package mention
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type parsed struct {
+	start int
+	name  string
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []parsed
+	}{
+		{"普通提及", "hi @Tom!", []parsed{{3, "Tom"}}},
+		{"邮箱不算提及", "mail a@b.com", nil},
+		{"紧跟中文之后仍算提及", "你好@张三，在吗", []parsed{{2, "张三"}}},
+		{"紧跟全角标点之后", "（@李四）", []parsed{{1, "李四"}}},
+		{"名字可含间隔号和下划线", "@李·四 @a_b-c", []parsed{{0, "李·四"}, {5, "a_b-c"}}},
+		{"@ 之后没有名字", "@ @. @@", nil},
+		{"偏移按 UTF-16 计算", "😀@Tom", []parsed{{2, "Tom"}}},
+		{"名字最长取 maxNameLength 个字符", "@" + strings.Repeat("a", maxNameLength+5), []parsed{{0, strings.Repeat("a", maxNameLength)}}},
+		{"连续的 @", "@Tom@Jerry", []parsed{{0, "Tom"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []parsed
+			for _, c := range parse(tt.text) {
+				got = append(got, parsed{c.start, string(c.name)})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	text := strings.Repeat("@abcdefghijklmnopqrstuvwxyz0123 ", 5000)
+	cands := parse(text)
+	if len(cands) != maxCandidates {
+		t.Fatalf("parse returned %d candidates, want %d", len(cands), maxCandidates)
+	}
+	// 查询参数个数有上限，远低于 Postgres 的 65535
+	if n := len(prefixes(cands)); n > maxCandidates*maxNameLength {
+		t.Errorf("prefixes returned %d names", n)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	users := map[string][]uint{
+		"张小明": {1},
+		"张小":  {2},
+		"Tom": {3},
+		"重名":  {4, 5},
+	}
+	tests := []struct {
+		name string
+		text string
+		want []Span
+	}{
+		{"取最长的匹配", "@张小明这句写得好", []Span{{UserID: 1, Start: 0, End: 4}}},
+		{"较短的名字", "@张小红", []Span{{UserID: 2, Start: 0, End: 3}}},
+		{"重名用户不记录", "@重名", nil},
+		{"没有对应用户", "@nobody", nil},
+		{"同一用户可以多次出现", "@Tom 和 @Tom", []Span{{UserID: 3, Start: 0, End: 4}, {UserID: 3, Start: 7, End: 11}}},
+		{"偏移按 UTF-16 计算", "😀 @Tom", []Span{{UserID: 3, Start: 3, End: 7}}},
+		{"中文之后的提及", "谢谢@Tom", []Span{{UserID: 3, Start: 2, End: 6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := match(parse(tt.text), users)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchLimit(t *testing.T) {
+	users := map[string][]uint{}
+	var b strings.Builder
+	for i := 0; i < maxMentions+5; i++ {
+		name := "u" + strings.Repeat("x", i)
+		users[name] = []uint{uint(i + 1)}
+		b.WriteString("@" + name + " ")
+	}
+	seen := map[uint]bool{}
+	for _, s := range match(parse(b.String()), users) {
+		seen[s.UserID] = true
+	}
+	if len(seen) != maxMentions {
+		t.Errorf("matched %d users, want %d", len(seen), maxMentions)
+	}
+}
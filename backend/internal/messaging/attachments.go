@@ -0,0 +1,35 @@
+// Package messaging 私信相关的后台维护任务。
+package messaging
+
+import (
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+)
+
+// 上传后超过该时长仍未随消息发送的附件会被清理
+const unsentAttachmentTTL = 24 * time.Hour
+
+// PruneAttachments 删除上传后 24 小时仍未发送的附件及其文件
+func PruneAttachments(db *gorm.DB) (int64, error) {
+	var stale []repo.MessageAttachment
+	if err := db.Where("message_id IS NULL AND created_at < ?", time.Now().Add(-unsentAttachmentTTL)).
+		Limit(500).Find(&stale).Error; err != nil {
+		return 0, err
+	}
+	var ids []uint
+	for _, a := range stale {
+		if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		ids = append(ids, a.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := db.Delete(&repo.MessageAttachment{}, ids)
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,118 @@
+package moderation
+
+import "unicode"
+
+// Matcher Aho-Corasick 多模式匹配器，按字符（rune）建树，中文无需分词即可匹配
+type Matcher struct {
+	nodes []acNode
+	words []string
+}
+
+type acNode struct {
+	next map[rune]int
+	fail int
+	word int // 以此结点结尾的词的下标，-1 表示没有
+	dict int // 沿失败链最近的词结尾结点，-1 表示没有
+}
+
+// NewMatcher 用词表构建匹配器；词会先经过 normalize，空词被忽略
+func NewMatcher(words []string) *Matcher {
+	m := &Matcher{nodes: []acNode{{next: map[rune]int{}, word: -1, dict: -1}}}
+	for _, w := range words {
+		runes := normalize(w)
+		if len(runes) == 0 {
+			continue
+		}
+		cur := 0
+		for _, r := range runes {
+			nxt, ok := m.nodes[cur].next[r]
+			if !ok {
+				m.nodes = append(m.nodes, acNode{next: map[rune]int{}, word: -1, dict: -1})
+				nxt = len(m.nodes) - 1
+				m.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		if m.nodes[cur].word < 0 {
+			m.nodes[cur].word = len(m.words)
+			m.words = append(m.words, w)
+		}
+	}
+
+	// 按层构建失败指针
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for f > 0 {
+				if _, ok := m.nodes[f].next[r]; ok {
+					break
+				}
+				f = m.nodes[f].fail
+			}
+			if nxt, ok := m.nodes[f].next[r]; ok && nxt != child {
+				m.nodes[child].fail = nxt
+			}
+			fail := m.nodes[child].fail
+			if m.nodes[fail].word >= 0 {
+				m.nodes[child].dict = fail
+			} else {
+				m.nodes[child].dict = m.nodes[fail].dict
+			}
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// Find 返回文本中出现的词（按首次出现的顺序去重）
+func (m *Matcher) Find(text string) []string {
+	if m == nil || len(m.words) == 0 {
+		return nil
+	}
+	var found []string
+	seen := map[int]bool{}
+	hit := func(n int) {
+		if w := m.nodes[n].word; w >= 0 && !seen[w] {
+			seen[w] = true
+			found = append(found, m.words[w])
+		}
+	}
+	cur := 0
+	for _, r := range normalize(text) {
+		for cur > 0 {
+			if _, ok := m.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		if nxt, ok := m.nodes[cur].next[r]; ok {
+			cur = nxt
+		}
+		hit(cur)
+		for d := m.nodes[cur].dict; d >= 0; d = m.nodes[d].dict {
+			hit(d)
+		}
+	}
+	return found
+}
+
+// normalize 统一全角/半角和大小写，并去掉空白、标点和符号，避免"敏 感*词"之类的规避
+func normalize(s string) []rune {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		out = append(out, unicode.ToLower(r))
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatcherFind(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  []string
+	}{
+		{
+			name:  "重叠的词都能命中",
+			words: []string{"he", "she", "his", "hers"},
+			text:  "ushers",
+			want:  []string{"she", "he", "hers"},
+		},
+		{
+			name:  "经失败链上的词结尾结点命中",
+			words: []string{"abcd", "bc"},
+			text:  "xabcx",
+			want:  []string{"bc"},
+		},
+		{
+			name:  "词是另一个词的后缀",
+			words: []string{"敏感词", "感词"},
+			text:  "这里有敏感词",
+			want:  []string{"敏感词", "感词"},
+		},
+		{
+			name:  "全角输入按半角匹配并忽略大小写",
+			words: []string{"foo", "Ｂａｒ"},
+			text:  "ＦＯＯ and bAR",
+			want:  []string{"foo", "Ｂａｒ"},
+		},
+		{
+			name:  "词中间夹空白和标点",
+			words: []string{"敏感词", "spam"},
+			text:  "敏 感*词，s.p-a　m！",
+			want:  []string{"敏感词", "spam"},
+		},
+		{
+			name:  "词表里带标点的词",
+			words: []string{"  【敏感】 ", "!!"},
+			text:  "敏感",
+			want:  []string{"  【敏感】 "},
+		},
+		{
+			name:  "重复出现只返回一次",
+			words: []string{"ab"},
+			text:  "ab ab ab",
+			want:  []string{"ab"},
+		},
+		{
+			name:  "没有命中",
+			words: []string{"abc"},
+			text:  "acb bca",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMatcher(tt.words).Find(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherNil(t *testing.T) {
+	var m *Matcher
+	if got := m.Find("anything"); got != nil {
+		t.Errorf("nil matcher Find = %q, want nil", got)
+	}
+	if got := NewMatcher(nil).Find("anything"); got != nil {
+		t.Errorf("empty matcher Find = %q, want nil", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Hello, World!", "helloworld"},
+		{"ＡＢＣ１２３", "abc123"},
+		{"敏 感*词", "敏感词"},
+		{"（全角）括号　空格", "全角括号空格"},
+		{"a+b=c $5 ^_^", "abc5"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := string(normalize(tt.in)); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package moderation 评论自动审核：敏感词、链接与灌水检测，以及可信用户自动通过。
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/rbac"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/settings"
+)
+
+// 灌水检测阈值
+const (
+	maxRepeatedRunes = 15               // 同一字符连续出现的次数上限
+	duplicateWindow  = 10 * time.Minute // 该时间内重复发布相同内容视为灌水
+	burstWindow      = time.Minute      // 该时间内发布超过 burstLimit 条视为刷屏
+	burstLimit       = 5
+	trustedLookback  = 30 * 24 * time.Hour // 该时间内有评论被拒绝或隐藏的用户不算可信
+)
+
+var linkPattern = regexp.MustCompile(`(?i)https?://|www\.`)
+
+// Decision 自动审核结果；Reason 写入评论，供审核员查看
+type Decision struct {
+	Status repo.CommentStatus
+	Reason string
+}
+
+// CheckComment 审核一条评论：命中屏蔽词自动拒绝；命中待审词、链接过多、疑似灌水或非可信用户转人工审核；
+// 其余自动通过。读取设置或统计失败时一律转人工审核。编辑已有评论时传入其 ID，检测重复时排除自身
+func CheckComment(db *gorm.DB, comment *repo.Comment) Decision {
+	content := comment.Content
+	if words := matcherFor(settings.Strings(db, settings.CommentBlockWords)).Find(content); len(words) > 0 {
+		return Decision{repo.CommentRejected, "命中屏蔽词：" + strings.Join(words, "、")}
+	}
+	if words := matcherFor(settings.Strings(db, settings.CommentReviewWords)).Find(content); len(words) > 0 {
+		return Decision{repo.CommentPending, "命中待审词：" + strings.Join(words, "、")}
+	}
+	if n, limit := len(linkPattern.FindAllStringIndex(content, -1)), settings.Int(db, settings.CommentMaxLinks, 2); n > limit {
+		return Decision{repo.CommentPending, fmt.Sprintf("包含 %d 个链接", n)}
+	}
+	if repeatedRunes(content) >= maxRepeatedRunes {
+		return Decision{repo.CommentPending, "疑似灌水：大量重复字符"}
+	}
+	if reason := spamReason(db, comment); reason != "" {
+		return Decision{repo.CommentPending, reason}
+	}
+
+	if !settings.Bool(db, settings.CommentAutoApprove, true) {
+		return Decision{repo.CommentPending, "未开启自动通过"}
+	}
+	if !trusted(db, comment.AuthorID) {
+		return Decision{repo.CommentPending, "非可信用户，等待人工审核"}
+	}
+	return Decision{repo.CommentApproved, "可信用户且未命中规则，自动通过"}
+}
+
+// repeatedRunes 返回同一字符最长的连续出现次数
+func repeatedRunes(s string) int {
+	longest, run := 0, 0
+	var prev rune
+	for i, r := range s {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = r
+	}
+	return longest
+}
+
+// spamReason 检查重复发布和刷屏，返回空字符串表示正常
+func spamReason(db *gorm.DB, comment *repo.Comment) string {
+	now := time.Now()
+	var dup int64
+	if err := db.Model(&repo.Comment{}).
+		Where("author_id = ? AND id <> ? AND content = ? AND created_at > ?",
+			comment.AuthorID, comment.ID, comment.Content, now.Add(-duplicateWindow)).
+		Count(&dup).Error; err != nil {
+		return "检测失败，转人工审核"
+	}
+	if dup > 0 {
+		return "疑似灌水：重复发布相同内容"
+	}
+	var recent int64
+	if err := db.Model(&repo.Comment{}).
+		Where("author_id = ? AND id <> ? AND created_at > ?", comment.AuthorID, comment.ID, now.Add(-burstWindow)).
+		Count(&recent).Error; err != nil {
+		return "检测失败，转人工审核"
+	}
+	if recent >= burstLimit {
+		return "疑似刷屏：短时间内发布过多评论"
+	}
+	return ""
+}
+
+// trusted 可进入管理后台的角色直接可信；其他用户需有足够多已通过的评论，且近期没有评论被拒绝或隐藏
+func trusted(db *gorm.DB, uid uint) bool {
+	var user repo.User
+	if err := db.Select("id", "role", "status").First(&user, uid).Error; err != nil || user.Status != "active" {
+		return false
+	}
+	staff, err := rbac.IsStaff(db, user.Role)
+	if err != nil {
+		return false
+	}
+	if staff {
+		return true
+	}
+
+	var approved int64
+	if err := db.Model(&repo.Comment{}).
+		Where("author_id = ? AND status = ?", uid, repo.CommentApproved).
+		Count(&approved).Error; err != nil {
+		return false
+	}
+	if approved < int64(settings.Int(db, settings.CommentTrustedMinApproved, 5)) {
+		return false
+	}
+	var bad int64
+	if err := db.Model(&repo.Comment{}).
+		Where("author_id = ? AND status IN ? AND updated_at > ?",
+			uid, []repo.CommentStatus{repo.CommentRejected, repo.CommentHidden}, time.Now().Add(-trustedLookback)).
+		Count(&bad).Error; err != nil {
+		return false
+	}
+	return bad == 0
+}
+
+// 词表不变时复用已构建的匹配器
+var matchers = struct {
+	sync.Mutex
+	byKey map[string]*Matcher
+}{byKey: map[string]*Matcher{}}
+
+func matcherFor(words []string) *Matcher {
+	if len(words) == 0 {
+		return nil
+	}
+	key := strings.Join(words, "\n")
+	matchers.Lock()
+	defer matchers.Unlock()
+	if m, ok := matchers.byKey[key]; ok {
+		return m
+	}
+	// 只保留当前在用的词表（屏蔽词和待审词各一份）
+	if len(matchers.byKey) >= 4 {
+		matchers.byKey = map[string]*Matcher{}
+	}
+	m := NewMatcher(words)
+	matchers.byKey[key] = m
+	return m
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/repo"
+)
+
+// 每类通知在摘要中最多列出的条数，其余只计数
+const digestItemsPerType = 5
+
+// digestInterval 各邮件频率两次发送之间的最短间隔
+var digestInterval = map[string]time.Duration{
+	repo.EmailInstant: 0,
+	repo.EmailDaily:   24 * time.Hour,
+	repo.EmailWeekly:  7 * 24 * time.Hour,
+}
+
+// BuildDigest 把未读通知按类型分组生成一封邮件；只有一条时直接以其标题为主题
+func BuildDigest(user *repo.User, items []repo.Notification, appURL string) mailer.Message {
+	msg := mailer.Message{To: []string{user.Email}}
+	if len(items) == 1 {
+		msg.Subject = "麦芒文学社 - " + items[0].Title
+	} else {
+		msg.Subject = fmt.Sprintf("麦芒文学社 - 你有 %d 条未读通知", len(items))
+	}
+
+	groups := map[repo.NotificationType][]repo.Notification{}
+	for _, n := range items {
+		groups[n.Type] = append(groups[n.Type], n)
+	}
+
+	var b strings.Builder
+	b.WriteString("你好，" + user.Name + "：\n\n")
+	for _, info := range Types {
+		list := groups[info.Type]
+		if len(list) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "【%s】%d 条\n", info.Description, len(list))
+		for i, n := range list {
+			if i == digestItemsPerType {
+				fmt.Fprintf(&b, "  …另有 %d 条\n", len(list)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  - %s（%s）\n", n.Content, n.CreatedAt.Format("01-02 15:04"))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("查看全部通知：" + appURL + "/profile/notifications\n")
+	b.WriteString("调整接收方式：" + appURL + "/profile/notification-settings\n")
+	msg.Body = b.String()
+	return msg
+}
+
+// SendDigests 为到期的用户发送通知邮件：即时频率的用户每次都发，
+// 每日/每周摘要距上次发送满间隔后才发。返回发送的邮件数
+func SendDigests(db *gorm.DB, m mailer.Mailer, appURL string) (int, error) {
+	var uids []uint
+	if err := db.Model(&repo.Notification{}).
+		Where("email_pending = ?", true).
+		Distinct("user_id").
+		Pluck("user_id", &uids).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, uid := range uids {
+		ok, err := sendDigest(db, m, uid, appURL)
+		if err != nil {
+			log.Printf("Send notification digest to user %d error: %v", uid, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest 处理单个用户的待发通知，返回是否发送了邮件
+func sendDigest(db *gorm.DB, m mailer.Mailer, uid uint, appURL string) (bool, error) {
+	now := time.Now()
+	frequency := DefaultEmailFrequency
+	var setting repo.NotificationSetting
+	err := db.Where("user_id = ?", uid).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if err == nil && ValidFrequency(setting.EmailFrequency) {
+		frequency = setting.EmailFrequency
+	}
+	if setting.LastDigestAt != nil && now.Sub(*setting.LastDigestAt) < digestInterval[frequency] {
+		return false, nil
+	}
+
+	var user repo.User
+	if err := db.First(&user, uid).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	// 先认领再发送：多个实例同时执行时，同一条通知只会被一个实例取到
+	var pending []repo.Notification
+	if err := db.Model(&pending).Clauses(clause.Returning{}).
+		Where("user_id = ? AND email_pending = ?", uid, true).
+		Update("email_pending", false).Error; err != nil {
+		return false, err
+	}
+	if len(pending) == 0 {
+		return false, nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	ids := make([]uint, 0, len(pending))
+	// 已在站内读过的不再发邮件
+	var items []repo.Notification
+	for _, n := range pending {
+		ids = append(ids, n.ID)
+		if n.ReadAt == nil {
+			items = append(items, n)
+		}
+	}
+
+	send := len(items) > 0 && user.ID != 0 && user.Status == "active" && user.Email != ""
+	if !send {
+		return false, nil
+	}
+	if err := m.Send(BuildDigest(&user, items, appURL)); err != nil {
+		// 发送失败时放回待发，下次重试
+		if rerr := db.Model(&repo.Notification{}).Where("id IN ?", ids).
+			Update("email_pending", true).Error; rerr != nil {
+			log.Printf("Restore pending notification emails error: %v", rerr)
+		}
+		return false, err
+	}
+	return true, db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_digest_at"}),
+	}).Create(&repo.NotificationSetting{UserID: uid, EmailFrequency: frequency, LastDigestAt: &now}).Error
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maimang/backend/internal/mailer"
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/testdb"
+)
+
+func TestBuildDigest(t *testing.T) {
+	user := &repo.User{Name: "小麦", Email: "wheat@example.com"}
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local)
+	item := func(typ repo.NotificationType, content string) repo.Notification {
+		return repo.Notification{Type: typ, Title: "标题" + content, Content: content, CreatedAt: at}
+	}
+	var many []repo.Notification
+	for i := 1; i <= digestItemsPerType+2; i++ {
+		many = append(many, item(repo.NotificationMention, fmt.Sprintf("提及%d", i)))
+	}
+
+	tests := []struct {
+		name    string
+		items   []repo.Notification
+		subject string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "只有一条时以标题为主题",
+			items:   []repo.Notification{item(repo.NotificationWorkApproved, "作品通过")},
+			subject: "麦芒文学社 - 标题作品通过",
+			want:    []string{"你好，小麦：", "【作品审核通过】1 条", "  - 作品通过（03-01 09:30）", "https://mm.test/profile/notifications"},
+		},
+		{
+			name: "按类型分组并按 Types 的顺序排列",
+			items: []repo.Notification{
+				item(repo.NotificationMessage, "私信"),
+				item(repo.NotificationWorkApproved, "作品通过"),
+				item(repo.NotificationMessage, "又一条私信"),
+			},
+			subject: "麦芒文学社 - 你有 3 条未读通知",
+			want:    []string{"【作品审核通过】1 条\n  - 作品通过", "【收到私信】2 条\n  - 私信（03-01 09:30）\n  - 又一条私信"},
+		},
+		{
+			name:    "每类最多列出 digestItemsPerType 条",
+			items:   many,
+			subject: fmt.Sprintf("麦芒文学社 - 你有 %d 条未读通知", len(many)),
+			want:    []string{fmt.Sprintf("提及%d", digestItemsPerType), "  …另有 2 条\n"},
+			notWant: []string{fmt.Sprintf("提及%d", digestItemsPerType+1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := BuildDigest(user, tt.items, "https://mm.test")
+			if len(msg.To) != 1 || msg.To[0] != user.Email {
+				t.Errorf("To = %v", msg.To)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(msg.Body, s) {
+					t.Errorf("body missing %q:\n%s", s, msg.Body)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(msg.Body, s) {
+					t.Errorf("body should not contain %q:\n%s", s, msg.Body)
+				}
+			}
+		})
+	}
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(mailer.Message) error { return errors.New("smtp down") }
+
+func TestSendDigests(t *testing.T) {
+	db := testdb.Open(t, &repo.User{}, &repo.Notification{}, &repo.NotificationSetting{})
+	active := repo.User{Name: "小麦", Email: "wheat@example.com", Status: "active"}
+	banned := repo.User{Name: "稗子", Email: "tare@example.com", Status: "banned"}
+	for _, u := range []*repo.User{&active, &banned} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := time.Now()
+	for _, n := range []repo.Notification{
+		{UserID: active.ID, Type: repo.NotificationWorkApproved, Title: "作品审核通过", Content: "a", EmailPending: true},
+		{UserID: active.ID, Type: repo.NotificationWorkComment, Title: "新评论", Content: "b", EmailPending: true, ReadAt: &read},
+		{UserID: banned.ID, Type: repo.NotificationWorkApproved, Title: "作品审核通过", Content: "c", EmailPending: true},
+	} {
+		if err := db.Create(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := func() int64 {
+		var n int64
+		db.Model(&repo.Notification{}).Where("email_pending = ?", true).Count(&n)
+		return n
+	}
+
+	// 发送失败时通知放回待发
+	if sent, _ := SendDigests(db, failingMailer{}, "https://mm.test"); sent != 0 {
+		t.Errorf("sent = %d with failing mailer", sent)
+	}
+	if n := pending(); n != 2 {
+		t.Errorf("%d notifications pending after failed send, want 2", n)
+	}
+
+	dir := t.TempDir()
+	sent, err := SendDigests(db, mailer.NewFileMailer(dir, "noreply@example.com"), "https://mm.test")
+	if err != nil || sent != 1 {
+		t.Fatalf("SendDigests = %d, %v, want 1", sent, err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("wrote %d mails, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: wheat@example.com") {
+		t.Errorf("mail not addressed to active user:\n%s", data)
+	}
+	if n := pending(); n != 0 {
+		t.Errorf("%d notifications still pending", n)
+	}
+
+	// 每日摘要未满间隔不再发送
+	db.Create(&repo.Notification{UserID: active.ID, Type: repo.NotificationWorkApproved, Title: "t", Content: "d", EmailPending: true})
+	if sent, _ := SendDigests(db, mailer.NewFileMailer(dir, "noreply@example.com"), "https://mm.test"); sent != 0 {
+		t.Errorf("sent = %d within daily interval", sent)
+	}
+}
@@ -0,0 +1,329 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/mention"
+	"maimang/backend/internal/realtime"
+	"maimang/backend/internal/repo"
+)
+
+// Send 按收件人的通知设置写入通知：关闭站内通知的只用于邮件，两者都关闭的不写入。
+// 失败只记录日志，不影响触发通知的业务
+func Send(db *gorm.DB, list ...repo.Notification) {
+	if len(list) == 0 {
+		return
+	}
+	uids := make([]uint, 0, len(list))
+	for _, n := range list {
+		uids = append(uids, n.UserID)
+	}
+	prefs, err := loadPreferences(db, uids)
+	if err != nil {
+		log.Printf("Load notification preferences error: %v", err)
+		return
+	}
+
+	out := list[:0]
+	for _, n := range list {
+		p := prefs.get(n.UserID, n.Type)
+		if !p.InApp && !p.Email {
+			continue
+		}
+		n.Silent = !p.InApp
+		n.EmailPending = p.Email
+		out = append(out, n)
+	}
+	if len(out) == 0 {
+		return
+	}
+	if err := db.CreateInBatches(out, 100).Error; err != nil {
+		log.Printf("Create notifications error: %v", err)
+		return
+	}
+	for _, n := range out {
+		if !n.Silent {
+			realtime.Publish(db, n.UserID, realtime.EventNotification, n, nil)
+		}
+	}
+}
+
+// WorkReviewed 通知作者作品审核结果，驳回时附带原因
+func WorkReviewed(db *gorm.DB, work *repo.Work, approved bool, note string) {
+	n := repo.Notification{
+		UserID:     work.AuthorID,
+		TargetType: "work",
+		TargetID:   work.ID,
+	}
+	if approved {
+		n.Type = repo.NotificationWorkApproved
+		n.Title = "作品审核通过"
+		n.Content = fmt.Sprintf("您的作品《%s》已通过审核并发布", work.Title)
+	} else {
+		n.Type = repo.NotificationWorkRejected
+		n.Title = "作品未通过审核"
+		n.Content = fmt.Sprintf("您的作品《%s》未通过审核", work.Title)
+		if note != "" {
+			n.Content += "，原因：" + note
+		}
+	}
+	Send(db, n)
+	if approved {
+		WorkMentions(db, work)
+	}
+}
+
+// CommentPublished 评论审核通过后通知评论者，并通知作品作者有新评论；回复则通知被回复的人，
+// 另外通知评论中 @ 到的用户。自己评论自己或对方已拉黑评论者时不通知
+func CommentPublished(db *gorm.DB, comment *repo.Comment) {
+	var work repo.Work
+	if err := db.Select("id", "title", "author_id").First(&work, comment.WorkID).Error; err != nil {
+		log.Printf("Load work for comment notification error: %v", err)
+		return
+	}
+	list := []repo.Notification{{
+		UserID:     comment.AuthorID,
+		Type:       repo.NotificationCommentApproved,
+		Title:      "评论审核通过",
+		Content:    fmt.Sprintf("您在《%s》下的评论已通过审核", work.Title),
+		TargetType: "comment",
+		TargetID:   comment.ID,
+	}}
+	if comment.ReplyToUserID != nil {
+		if to := *comment.ReplyToUserID; to != comment.AuthorID && !blockers(db, comment.AuthorID, []uint{to})[to] {
+			list = append(list, repo.Notification{
+				UserID:     to,
+				Type:       repo.NotificationCommentReply,
+				Title:      "新回复",
+				Content:    fmt.Sprintf("%s 在《%s》下回复了您的评论", userName(db, comment.AuthorID), work.Title),
+				ActorID:    &comment.AuthorID,
+				TargetType: "comment",
+				TargetID:   comment.ID,
+			})
+		}
+	} else if work.AuthorID != comment.AuthorID && !blockers(db, comment.AuthorID, []uint{work.AuthorID})[work.AuthorID] {
+		list = append(list, repo.Notification{
+			UserID:     work.AuthorID,
+			Type:       repo.NotificationWorkComment,
+			Title:      "新评论",
+			Content:    fmt.Sprintf("%s 评论了您的作品《%s》", userName(db, comment.AuthorID), work.Title),
+			ActorID:    &comment.AuthorID,
+			TargetType: "work",
+			TargetID:   work.ID,
+		})
+	}
+	Send(db, list...)
+	mentioned(db, mention.TargetComment, comment.ID, comment.AuthorID, fmt.Sprintf("在《%s》的评论中", work.Title))
+}
+
+// CommentMentions 通知已公开评论中新 @ 到的用户，用于编辑已公开的评论后
+func CommentMentions(db *gorm.DB, comment *repo.Comment) {
+	var work repo.Work
+	if err := db.Select("id", "title").First(&work, comment.WorkID).Error; err != nil {
+		log.Printf("Load work for mention notification error: %v", err)
+		return
+	}
+	mentioned(db, mention.TargetComment, comment.ID, comment.AuthorID, fmt.Sprintf("在《%s》的评论中", work.Title))
+}
+
+// WorkMentions 通知已发布作品中新 @ 到的用户
+func WorkMentions(db *gorm.DB, work *repo.Work) {
+	mentioned(db, mention.TargetWork, work.ID, work.AuthorID, fmt.Sprintf("在作品《%s》中", work.Title))
+}
+
+// mentioned 发送尚未通知的提及；提及自己、已封禁或拉黑了作者的用户不通知，但同样标记为已处理
+func mentioned(db *gorm.DB, targetType string, targetID, authorID uint, where string) {
+	var pending []repo.Mention
+	if err := db.Where("target_type = ? AND target_id = ? AND notified_at IS NULL", targetType, targetID).
+		Find(&pending).Error; err != nil {
+		log.Printf("Load mentions error: %v", err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(pending))
+	for _, m := range pending {
+		ids = append(ids, m.ID)
+	}
+	if err := db.Model(&repo.Mention{}).Where("id IN ?", ids).
+		Update("notified_at", time.Now()).Error; err != nil {
+		log.Printf("Mark mentions notified error: %v", err)
+		return
+	}
+
+	uids := make([]uint, 0, len(pending))
+	for _, m := range pending {
+		uids = append(uids, m.UserID)
+	}
+	// 已封禁和拉黑了作者的用户各用一次查询取出，不逐个收件人查询
+	var banned []uint
+	db.Model(&repo.User{}).Where("id IN ? AND status = ?", uids, "banned").Pluck("id", &banned)
+	skip := blockers(db, authorID, uids)
+	skip[authorID] = true
+	for _, id := range banned {
+		skip[id] = true
+	}
+
+	content := fmt.Sprintf("%s %s提到了您", userName(db, authorID), where)
+	var list []repo.Notification
+	for _, m := range pending {
+		if skip[m.UserID] {
+			continue
+		}
+		skip[m.UserID] = true
+		list = append(list, repo.Notification{
+			UserID:     m.UserID,
+			Type:       repo.NotificationMention,
+			Title:      "有人提到了您",
+			Content:    content,
+			ActorID:    &authorID,
+			TargetType: targetType,
+			TargetID:   targetID,
+		})
+	}
+	Send(db, list...)
+}
+
+// MessageReceived 通知收件人有新私信；同一发信人尚未读的通知会被合并更新，不逐条累加
+func MessageReceived(db *gorm.DB, msg *repo.Message) {
+	content := fmt.Sprintf("%s 给您发来私信", userName(db, msg.FromUserID))
+	var existing repo.Notification
+	err := db.Where("user_id = ? AND type = ? AND actor_id = ? AND read_at IS NULL", msg.ToUserID, repo.NotificationMessage, msg.FromUserID).
+		Order("id DESC").First(&existing).Error
+	switch {
+	case err == nil:
+		// 合并到未读的私信通知，按当前偏好重新安排邮件并推送
+		prefs, err := loadPreferences(db, []uint{msg.ToUserID})
+		if err != nil {
+			log.Printf("Load notification preferences error: %v", err)
+			return
+		}
+		p := prefs.get(msg.ToUserID, repo.NotificationMessage)
+		if !p.InApp && !p.Email {
+			return
+		}
+		if err := db.Model(&existing).Updates(map[string]interface{}{
+			"content":       content,
+			"created_at":    time.Now(),
+			"silent":        !p.InApp,
+			"email_pending": p.Email,
+		}).Error; err != nil {
+			log.Printf("Update message notification error: %v", err)
+			return
+		}
+		if !existing.Silent {
+			realtime.Publish(db, existing.UserID, realtime.EventNotification, existing, nil)
+		}
+		return
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		log.Printf("Load message notification error: %v", err)
+		return
+	}
+	Send(db, repo.Notification{
+		UserID:     msg.ToUserID,
+		Type:       repo.NotificationMessage,
+		Title:      "新私信",
+		Content:    content,
+		ActorID:    &msg.FromUserID,
+		TargetType: "user",
+		TargetID:   msg.FromUserID,
+	})
+}
+
+// MessagesRead 已读某人的私信后，同时将对应的私信通知标为已读
+func MessagesRead(db *gorm.DB, uid, fromUserID uint) {
+	if err := db.Model(&repo.Notification{}).
+		Where("user_id = ? AND type = ? AND actor_id = ? AND read_at IS NULL", uid, repo.NotificationMessage, fromUserID).
+		Update("read_at", time.Now()).Error; err != nil {
+		log.Printf("Mark message notifications read error: %v", err)
+	}
+}
+
+var activityStatusText = map[repo.ActivityStatus]string{
+	repo.ActivityUpcoming:  "即将开始",
+	repo.ActivityOngoing:   "已开始",
+	repo.ActivityCompleted: "已结束",
+	repo.ActivityCancelled: "已取消",
+}
+
+// ActivityStatusChanged 通知活动的全部报名者活动状态变更
+func ActivityStatusChanged(db *gorm.DB, activity *repo.Activity, status repo.ActivityStatus) {
+	text, ok := activityStatusText[status]
+	if !ok {
+		return
+	}
+	var uids []uint
+	if err := db.Model(&repo.ActivityParticipant{}).
+		Where("activity_id = ?", activity.ID).
+		Pluck("user_id", &uids).Error; err != nil {
+		log.Printf("Load activity participants error: %v", err)
+		return
+	}
+	list := make([]repo.Notification, 0, len(uids))
+	for _, uid := range uids {
+		list = append(list, repo.Notification{
+			UserID:     uid,
+			Type:       repo.NotificationActivityStatus,
+			Title:      "活动状态变更",
+			Content:    fmt.Sprintf("您报名的活动「%s」%s", activity.Title, text),
+			TargetType: "activity",
+			TargetID:   activity.ID,
+		})
+	}
+	Send(db, list...)
+}
+
+// UnreadCount 返回用户的未读通知数
+func UnreadCount(db *gorm.DB, uid uint) (int64, error) {
+	var count int64
+	err := db.Model(&repo.Notification{}).Where("user_id = ? AND silent = ? AND read_at IS NULL", uid, false).Count(&count).Error
+	return count, err
+}
+
+// MarkRead 将用户的一条通知标为已读，通知不存在时返回 gorm.ErrRecordNotFound
+func MarkRead(db *gorm.DB, uid, id uint) error {
+	var n repo.Notification
+	if err := db.Where("id = ? AND user_id = ?", id, uid).First(&n).Error; err != nil {
+		return err
+	}
+	if n.ReadAt != nil {
+		return nil
+	}
+	return db.Model(&n).Update("read_at", time.Now()).Error
+}
+
+// MarkAllRead 将用户的全部未读通知标为已读，返回更新的条数
+func MarkAllRead(db *gorm.DB, uid uint) (int64, error) {
+	res := db.Model(&repo.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", uid).
+		Update("read_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+// blockers 返回 uids 中拉黑了 other 的用户
+func blockers(db *gorm.DB, other uint, uids []uint) map[uint]bool {
+	set := make(map[uint]bool)
+	if len(uids) == 0 {
+		return set
+	}
+	var ids []uint
+	db.Model(&repo.UserBlock{}).Where("blocked_id = ? AND user_id IN ?", other, uids).Pluck("user_id", &ids)
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func userName(db *gorm.DB, uid uint) string {
+	var user repo.User
+	if err := db.Select("id", "name").First(&user, uid).Error; err != nil || user.Name == "" {
+		return "某用户"
+	}
+	return user.Name
+}
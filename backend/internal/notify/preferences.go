@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+)
+
+var (
+	ErrUnknownType      = errors.New("unknown notification type")
+	ErrUnknownFrequency = errors.New("unknown email frequency")
+)
+
+// TypeInfo 通知类型及其默认接收方式
+type TypeInfo struct {
+	Type         repo.NotificationType `json:"type"`
+	Description  string                `json:"description"`
+	DefaultEmail bool                  `json:"default_email"`
+}
+
+// Types 可配置的通知类型；审核结果和活动变更默认也发邮件
+var Types = []TypeInfo{
+	{repo.NotificationWorkApproved, "作品审核通过", true},
+	{repo.NotificationWorkRejected, "作品未通过审核", true},
+	{repo.NotificationWorkComment, "作品收到新评论", false},
+	{repo.NotificationCommentApproved, "评论通过审核", false},
+	{repo.NotificationCommentReply, "评论收到回复", false},
+	{repo.NotificationMention, "有人 @ 了我", false},
+	{repo.NotificationMessage, "收到私信", false},
+	{repo.NotificationActivityStatus, "报名的活动状态变更", true},
+}
+
+// DefaultEmailFrequency 未设置时的邮件频率
+const DefaultEmailFrequency = repo.EmailDaily
+
+// Preference 某类通知的接收方式
+type Preference struct {
+	Type        repo.NotificationType `json:"type"`
+	Description string                `json:"description"`
+	InApp       bool                  `json:"in_app"`
+	Email       bool                  `json:"email"`
+}
+
+// Settings 用户的完整通知设置
+type Settings struct {
+	EmailFrequency string       `json:"email_frequency"`
+	Types          []Preference `json:"types"`
+}
+
+func typeInfo(t repo.NotificationType) (TypeInfo, bool) {
+	for _, info := range Types {
+		if info.Type == t {
+			return info, true
+		}
+	}
+	return TypeInfo{}, false
+}
+
+// ValidFrequency 判断邮件频率是否可识别
+func ValidFrequency(f string) bool {
+	return f == repo.EmailInstant || f == repo.EmailDaily || f == repo.EmailWeekly
+}
+
+// LoadSettings 读取用户通知设置，未设置的项填入默认值
+func LoadSettings(db *gorm.DB, uid uint) (Settings, error) {
+	out := Settings{EmailFrequency: DefaultEmailFrequency}
+
+	var setting repo.NotificationSetting
+	err := db.Where("user_id = ?", uid).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return out, err
+	}
+	if err == nil && ValidFrequency(setting.EmailFrequency) {
+		out.EmailFrequency = setting.EmailFrequency
+	}
+
+	prefs, err := loadPreferences(db, []uint{uid})
+	if err != nil {
+		return out, err
+	}
+	for _, info := range Types {
+		p := prefs.get(uid, info.Type)
+		p.Description = info.Description
+		out.Types = append(out.Types, p)
+	}
+	return out, nil
+}
+
+// SaveSettings 保存用户通知设置；frequency 为空表示不修改，prefs 只需包含要修改的类型
+func SaveSettings(db *gorm.DB, uid uint, frequency string, prefs []Preference) error {
+	if frequency != "" && !ValidFrequency(frequency) {
+		return ErrUnknownFrequency
+	}
+	for _, p := range prefs {
+		if _, ok := typeInfo(p.Type); !ok {
+			return ErrUnknownType
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if frequency != "" {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"email_frequency", "updated_at"}),
+			}).Create(&repo.NotificationSetting{UserID: uid, EmailFrequency: frequency}).Error; err != nil {
+				return err
+			}
+		}
+		for _, p := range prefs {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+				DoUpdates: clause.AssignmentColumns([]string{"in_app", "email"}),
+			}).Create(&repo.NotificationPreference{UserID: uid, Type: p.Type, InApp: p.InApp, Email: p.Email}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type preferenceSet map[uint]map[repo.NotificationType]repo.NotificationPreference
+
+// get 返回用户对某类通知的接收方式，未设置时取默认值
+func (s preferenceSet) get(uid uint, t repo.NotificationType) Preference {
+	if p, ok := s[uid][t]; ok {
+		return Preference{Type: t, InApp: p.InApp, Email: p.Email}
+	}
+	info, _ := typeInfo(t)
+	return Preference{Type: t, InApp: true, Email: info.DefaultEmail}
+}
+
+func loadPreferences(db *gorm.DB, uids []uint) (preferenceSet, error) {
+	var rows []repo.NotificationPreference
+	if err := db.Where("user_id IN ?", uids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	set := preferenceSet{}
+	for _, r := range rows {
+		if set[r.UserID] == nil {
+			set[r.UserID] = map[repo.NotificationType]repo.NotificationPreference{}
+		}
+		set[r.UserID][r.Type] = r
+	}
+	return set, nil
+}
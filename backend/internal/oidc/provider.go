@@ -0,0 +1,334 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oidc provider")
+	ErrEmailNotAllowed = errors.New("email domain not allowed")
+)
+
+// ProviderConfig 对应 config.yaml 中 OIDC_PROVIDERS 下的一项
+type ProviderConfig struct {
+	DisplayName    string   `mapstructure:"display_name"`
+	Issuer         string   `mapstructure:"issuer"`
+	ClientID       string   `mapstructure:"client_id"`
+	ClientSecret   string   `mapstructure:"client_secret"`
+	Scopes         []string `mapstructure:"scopes"`
+	AllowSignup    bool     `mapstructure:"allow_signup"`    // 是否为未注册的邮箱自动创建账号
+	AllowedDomains []string `mapstructure:"allowed_domains"` // 允许的邮箱域名，为空表示不限制
+}
+
+// Provider 一个已配置的身份提供方，发现文档与公钥按需拉取并缓存
+type Provider struct {
+	Name        string
+	RedirectURL string
+	cfg         ProviderConfig
+	client      *http.Client
+
+	mu     sync.Mutex
+	meta   *discovery
+	metaAt time.Time
+
+	keysMu sync.Mutex
+	keys   map[string]interface{}
+	keysAt time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// 发现文档缓存时间
+const discoveryTTL = 24 * time.Hour
+
+var (
+	regMu     sync.RWMutex
+	providers = map[string]*Provider{}
+)
+
+// Configure 根据配置注册身份提供方；callbackBase 为后端对外地址，回调路径固定为
+// /api/v1/auth/oidc/<name>/callback，需要在身份提供方登记
+func Configure(configs map[string]ProviderConfig, callbackBase string) error {
+	next := map[string]*Provider{}
+	for name, cfg := range configs {
+		name = strings.ToLower(name)
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return fmt.Errorf("oidc provider %q: issuer and client_id are required", name)
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"openid", "email", "profile"}
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = name
+		}
+		next[name] = &Provider{
+			Name:        name,
+			RedirectURL: strings.TrimRight(callbackBase, "/") + "/api/v1/auth/oidc/" + name + "/callback",
+			cfg:         cfg,
+			client:      &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	regMu.Lock()
+	providers = next
+	regMu.Unlock()
+	return nil
+}
+
+// Lookup 按名称查找身份提供方
+func Lookup(name string) (*Provider, error) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// List 返回全部已配置的身份提供方，按名称排序
+func List() []*Provider {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	out := make([]*Provider, 0, len(providers))
+	for _, p := range providers {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// DisplayName 登录按钮上显示的名称
+func (p *Provider) DisplayName() string { return p.cfg.DisplayName }
+
+// AllowSignup 是否允许自动创建账号
+func (p *Provider) AllowSignup() bool { return p.cfg.AllowSignup }
+
+// EmailAllowed 检查邮箱域名是否在允许范围内
+func (p *Provider) EmailAllowed(email string) bool {
+	if len(p.cfg.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range p.cfg.AllowedDomains {
+		if strings.ToLower(d) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthCodeURL 生成跳转到身份提供方的授权地址（授权码模式 + PKCE）
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Identity 身份提供方确认的用户信息
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	ErrorDesc   string `json:"error_description"`
+}
+
+// Exchange 用授权码换取令牌并校验 ID Token；ID Token 缺少邮箱时从 userinfo 补全
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var tok tokenResponse
+	if err := p.doJSON(req, &tok); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("token exchange: %s %s", tok.Error, tok.ErrorDesc)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("token exchange: missing id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, tok.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}
+	if id.Email == "" && meta.UserinfoEndpoint != "" && tok.AccessToken != "" {
+		if err := p.fillFromUserinfo(ctx, meta.UserinfoEndpoint, tok.AccessToken, id); err != nil {
+			return nil, err
+		}
+	}
+	return id, nil
+}
+
+func (p *Provider) fillFromUserinfo(ctx context.Context, endpoint, accessToken string, id *Identity) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	var info struct {
+		Subject       string  `json:"sub"`
+		Email         string  `json:"email"`
+		EmailVerified boolish `json:"email_verified"`
+		Name          string  `json:"name"`
+	}
+	if err := p.doJSON(req, &info); err != nil {
+		return fmt.Errorf("userinfo: %w", err)
+	}
+	// userinfo 的 sub 必须与 ID Token 一致，否则可能被替换
+	if info.Subject != id.Subject {
+		return errors.New("userinfo: subject mismatch")
+	}
+	id.Email = strings.ToLower(strings.TrimSpace(info.Email))
+	id.EmailVerified = bool(info.EmailVerified)
+	if id.Name == "" {
+		id.Name = info.Name
+	}
+	return nil
+}
+
+// discover 读取并缓存发现文档，签发方必须与配置一致
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil && time.Since(p.metaAt) < discoveryTTL {
+		return p.meta, nil
+	}
+	endpoint := strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	var meta discovery
+	if err := p.doJSON(req, &meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != strings.TrimRight(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete metadata")
+	}
+	p.meta = &meta
+	p.metaAt = time.Now()
+	return p.meta, nil
+}
+
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// 令牌端点的错误响应同样是 JSON，交给调用方判断
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// NewRandom 生成 URL 安全的随机串，用于 state、nonce 与 PKCE verifier
+func NewRandom() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// boolish 兼容部分身份提供方把 email_verified 返回为字符串
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP 本地模拟的身份提供方：发现文档、JWKS、令牌端点（校验 PKCE）与 userinfo
+type mockIdP struct {
+	t         *testing.T
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string // 授权请求中的 code_challenge
+	nonce     string // 授权请求中的 nonce，写入 ID Token
+
+	signKey  *rsa.PrivateKey        // 签发 ID Token 的私钥，默认为已发布的 key
+	method   jwt.SigningMethod      // 签名算法，默认 RS256
+	claims   func(jwt.MapClaims)    // 调整 ID Token 的声明
+	userinfo map[string]interface{} // userinfo 端点返回的内容
+	issuer   string                 // 发现文档中的签发方，默认为服务地址
+}
+
+const (
+	mockClientID = "maimang-web"
+	mockSecret   = "s3cret"
+	mockCode     = "auth-code"
+)
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIdP{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/userinfo", m.userinfoHandler)
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+func (m *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := m.issuer
+	if issuer == "" {
+		issuer = m.srv.URL
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": m.srv.URL + "/authorize",
+		"token_endpoint":         m.srv.URL + "/token",
+		"userinfo_endpoint":      m.srv.URL + "/userinfo",
+		"jwks_uri":               m.srv.URL + "/jwks",
+	})
+}
+
+func (m *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	enc := base64.RawURLEncoding
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+		{"kid": "enc", "kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{
+			"kid": "k1", "kty": "RSA", "use": "sig",
+			"n": enc.EncodeToString(m.key.N.Bytes()),
+			"e": enc.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		},
+	}})
+}
+
+func (m *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		m.t.Errorf("parse token request: %v", err)
+	}
+	id, secret, _ := r.BasicAuth()
+	sum := codeChallenge(r.PostForm.Get("code_verifier"))
+	switch {
+	case id != mockClientID || secret != mockSecret:
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	case r.PostForm.Get("code") != mockCode || sum != m.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.srv.URL,
+		"aud":            mockClientID,
+		"sub":            "idp-user-1",
+		"email":          " Alice@Example.COM ",
+		"email_verified": true,
+		"name":           "Alice",
+		"nonce":          m.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	if m.claims != nil {
+		m.claims(claims)
+	}
+	method, key := m.method, interface{}(m.signKey)
+	if method == nil {
+		method = jwt.SigningMethodRS256
+	}
+	if m.signKey == nil {
+		key = m.key
+	}
+	if method == jwt.SigningMethodHS256 {
+		key = []byte(mockSecret)
+	}
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		m.t.Fatalf("sign id_token: %v", err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at-1", "id_token": signed})
+}
+
+func (m *mockIdP) userinfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer at-1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(m.userinfo)
+}
+
+// provider 为模拟的身份提供方新建 Provider，避免测试之间共享发现文档和公钥缓存
+func (m *mockIdP) provider() *Provider {
+	return &Provider{
+		Name:        "mock",
+		RedirectURL: "https://mm.test/api/v1/auth/oidc/mock/callback",
+		cfg:         ProviderConfig{Issuer: m.srv.URL, ClientID: mockClientID, ClientSecret: mockSecret, Scopes: []string{"openid", "email"}},
+		client:      m.srv.Client(),
+	}
+}
+
+// login 走完一次授权码流程：生成授权地址、记录 code_challenge，再用授权码换取身份
+func (m *mockIdP) login(p *Provider, mutateNonce, mutateVerifier bool) (*Identity, error) {
+	ctx := context.Background()
+	state, _ := NewRandom()
+	nonce, _ := NewRandom()
+	verifier, _ := NewRandom()
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	m.challenge, m.nonce = q.Get("code_challenge"), q.Get("nonce")
+	if q.Get("state") != state || q.Get("nonce") != nonce || q.Get("client_id") != mockClientID ||
+		q.Get("redirect_uri") != p.RedirectURL || q.Get("code_challenge_method") != "S256" {
+		m.t.Errorf("unexpected authorization request %s", authURL)
+	}
+	if mutateNonce {
+		nonce = "other-nonce"
+	}
+	if mutateVerifier {
+		verifier = "other-verifier"
+	}
+	return p.Exchange(ctx, mockCode, verifier, nonce)
+}
+
+func TestProviderLogin(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		setup         func(m *mockIdP)
+		wrongNonce    bool
+		wrongVerifier bool
+		wantErr       string
+		wantEmail     string
+		wantVerified  bool
+	}{
+		{name: "正常登录，邮箱统一为小写", wantEmail: "alice@example.com", wantVerified: true},
+		{
+			name:         "email_verified 为字符串",
+			setup:        func(m *mockIdP) { m.claims = func(c jwt.MapClaims) { c["email_verified"] = "true" } },
+			wantEmail:    "alice@example.com",
+			wantVerified: true,
+		},
+		{
+			name: "ID Token 没有邮箱时从 userinfo 补全",
+			setup: func(m *mockIdP) {
+				m.claims = func(c jwt.MapClaims) { delete(c, "email"); delete(c, "email_verified") }
+				m.userinfo = map[string]interface{}{"sub": "idp-user-1", "email": "bob@example.com", "email_verified": false}
+			},
+			wantEmail: "bob@example.com",
+		},
+		{
+			name: "userinfo 的 sub 不一致",
+			setup: func(m *mockIdP) {
+				m.claims = func(c jwt.MapClaims) { delete(c, "email") }
+				m.userinfo = map[string]interface{}{"sub": "someone-else", "email": "evil@example.com"}
+			},
+			wantErr: "subject mismatch",
+		},
+		{name: "nonce 不一致", wrongNonce: true, wantErr: "nonce mismatch"},
+		{name: "PKCE verifier 不一致", wrongVerifier: true, wantErr: "invalid_grant"},
+		{
+			name:    "受众不是本应用",
+			setup:   func(m *mockIdP) { m.claims = func(c jwt.MapClaims) { c["aud"] = "another-app" } },
+			wantErr: "verify id_token",
+		},
+		{
+			name:    "ID Token 已过期",
+			setup:   func(m *mockIdP) { m.claims = func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() } },
+			wantErr: "verify id_token",
+		},
+		{
+			name:    "签发方不一致",
+			setup:   func(m *mockIdP) { m.claims = func(c jwt.MapClaims) { c["iss"] = "https://evil.test" } },
+			wantErr: "verify id_token",
+		},
+		{
+			name:    "缺少 sub",
+			setup:   func(m *mockIdP) { m.claims = func(c jwt.MapClaims) { delete(c, "sub") } },
+			wantErr: "missing subject",
+		},
+		{name: "未发布的私钥签名", setup: func(m *mockIdP) { m.signKey = otherKey }, wantErr: "verify id_token"},
+		{name: "对称算法签名", setup: func(m *mockIdP) { m.method = jwt.SigningMethodHS256 }, wantErr: "verify id_token"},
+		{name: "发现文档的签发方不一致", setup: func(m *mockIdP) { m.issuer = "https://evil.test" }, wantErr: "issuer mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIdP(t)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			id, err := m.login(m.provider(), tt.wrongNonce, tt.wrongVerifier)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.Subject != "idp-user-1" || id.Email != tt.wantEmail || id.EmailVerified != tt.wantVerified {
+				t.Errorf("identity = %+v, want email %s verified %v", id, tt.wantEmail, tt.wantVerified)
+			}
+		})
+	}
+}
+
+func TestProviderClientAuth(t *testing.T) {
+	m := newMockIdP(t)
+	p := m.provider()
+	p.cfg.ClientSecret = "wrong"
+	if _, err := m.login(p, false, false); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("error = %v, want invalid_client", err)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer func() {
+		regMu.Lock()
+		providers = map[string]*Provider{}
+		regMu.Unlock()
+	}()
+	err := Configure(map[string]ProviderConfig{
+		"Campus": {Issuer: "https://sso.example.edu", ClientID: "c", AllowedDomains: []string{"Example.EDU"}},
+	}, "https://mm.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Lookup("campus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RedirectURL != "https://mm.test/api/v1/auth/oidc/campus/callback" || p.DisplayName() != "campus" {
+		t.Errorf("provider = %s %s", p.RedirectURL, p.DisplayName())
+	}
+	for email, want := range map[string]bool{
+		"a@example.edu":      true,
+		"a@EXAMPLE.edu":      true,
+		"a@mail.example.edu": false,
+		"example.edu":        false,
+	} {
+		if got := p.EmailAllowed(email); got != want {
+			t.Errorf("EmailAllowed(%q) = %v, want %v", email, got, want)
+		}
+	}
+	if err := Configure(map[string]ProviderConfig{"bad": {Issuer: "https://x"}}, ""); err == nil {
+		t.Error("provider without client_id accepted")
+	}
+}
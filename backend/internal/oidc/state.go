@@ -0,0 +1,66 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+)
+
+// 登录流程需在该时间内完成
+const stateTTL = 10 * time.Minute
+
+var ErrStateInvalid = errors.New("invalid oauth state")
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveState 保存一次登录发起的上下文，返回 state、nonce 与 PKCE verifier
+func SaveState(db *gorm.DB, provider, redirectTo string) (state, nonce, verifier string, err error) {
+	if state, err = NewRandom(); err != nil {
+		return
+	}
+	if nonce, err = NewRandom(); err != nil {
+		return
+	}
+	if verifier, err = NewRandom(); err != nil {
+		return
+	}
+	err = db.Create(&repo.OAuthState{
+		StateHash:    hashState(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectTo:   redirectTo,
+		ExpiresAt:    time.Now().Add(stateTTL),
+	}).Error
+	return
+}
+
+// ConsumeState 取出并删除 state，保证每个 state 只能使用一次
+func ConsumeState(db *gorm.DB, provider, state string) (*repo.OAuthState, error) {
+	var rows []repo.OAuthState
+	err := db.Clauses(clause.Returning{}).
+		Where("state_hash = ?", hashState(state)).
+		Delete(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || rows[0].Provider != provider || time.Now().After(rows[0].ExpiresAt) {
+		return nil, ErrStateInvalid
+	}
+	return &rows[0], nil
+}
+
+// PruneStates 删除已过期、未完成的登录状态
+func PruneStates(db *gorm.DB) (int64, error) {
+	res := db.Where("expires_at < ?", time.Now()).Delete(&repo.OAuthState{})
+	return res.RowsAffected, res.Error
+}
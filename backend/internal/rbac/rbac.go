@@ -0,0 +1,250 @@
+package rbac
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"maimang/backend/internal/repo"
+)
+
+// 权限点
+const (
+	DashboardView         = "dashboard.view"
+	UsersRead             = "users.read"
+	UsersWrite            = "users.write"
+	UsersBan              = "users.ban"
+	UsersDelete           = "users.delete"
+	UsersApprove          = "users.approve"
+	InvitesManage         = "invites.manage"
+	SecurityManage        = "security.manage"
+	WorksReview           = "works.review"
+	CommentsReview        = "comments.review"
+	ModerationManage      = "moderation.manage"
+	ActivitiesManage      = "activities.manage"
+	ActivitiesParticipant = "activities.participants"
+	CarouselsManage       = "carousels.manage"
+	AnnouncementsManage   = "announcements.manage"
+	MaterialsManage       = "materials.manage"
+	SettingsRead          = "settings.read"
+	SettingsWrite         = "settings.write"
+)
+
+// PermissionInfo 权限点说明
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// All 代码中声明的全部权限点
+var All = []PermissionInfo{
+	{DashboardView, "查看仪表盘与统计数据"},
+	{UsersRead, "查看用户列表与详情"},
+	{UsersWrite, "编辑用户资料"},
+	{UsersBan, "封禁、解封及修改用户状态"},
+	{UsersDelete, "删除用户"},
+	{UsersApprove, "审核注册申请"},
+	{InvitesManage, "生成与作废邀请码"},
+	{SecurityManage, "管理登录锁定、登录会话与个人访问令牌"},
+	{WorksReview, "审核作品"},
+	{CommentsReview, "审核评论"},
+	{ModerationManage, "处理私信举报"},
+	{ActivitiesManage, "创建、编辑、删除活动"},
+	{ActivitiesParticipant, "查看活动报名名单"},
+	{CarouselsManage, "管理轮播图"},
+	{AnnouncementsManage, "管理公告"},
+	{MaterialsManage, "管理素材"},
+	{SettingsRead, "查看系统设置"},
+	{SettingsWrite, "修改系统设置"},
+}
+
+// Roles 可配置授权的角色；超级管理员拥有全部权限，不可配置
+var Roles = []repo.Role{repo.RoleAdmin, repo.RoleEditor, repo.RoleReviewer, repo.RoleMember, repo.RoleVisitor}
+
+// Defaults 各角色的默认授权
+var Defaults = map[repo.Role][]string{
+	repo.RoleAdmin: {
+		DashboardView, UsersRead, UsersWrite, UsersBan, UsersDelete, UsersApprove, InvitesManage, SecurityManage,
+		WorksReview, CommentsReview, ModerationManage, ActivitiesManage, ActivitiesParticipant,
+		CarouselsManage, AnnouncementsManage, MaterialsManage, SettingsRead, SettingsWrite,
+	},
+	repo.RoleEditor: {
+		DashboardView, UsersRead, ActivitiesManage, ActivitiesParticipant,
+		CarouselsManage, AnnouncementsManage, MaterialsManage, SettingsRead,
+	},
+	repo.RoleReviewer: {
+		DashboardView, UsersRead, WorksReview, CommentsReview, ModerationManage,
+	},
+	repo.RoleMember:  {},
+	repo.RoleVisitor: {},
+}
+
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// IsKnown 判断权限点是否已声明
+func IsKnown(perm string) bool {
+	for _, p := range All {
+		if p.Name == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConfigurable 判断角色是否可以编辑授权
+func IsConfigurable(role repo.Role) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Seed 写入新出现的权限点及其默认授权；已存在的权限点不再改动，保留管理员的调整
+func Seed(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing []string
+		if err := tx.Model(&repo.Permission{}).Pluck("name", &existing).Error; err != nil {
+			return err
+		}
+		known := map[string]bool{}
+		for _, name := range existing {
+			known[name] = true
+		}
+		for _, p := range All {
+			if known[p.Name] {
+				continue
+			}
+			if err := tx.Create(&repo.Permission{Name: p.Name, Description: p.Description}).Error; err != nil {
+				return err
+			}
+			for role, perms := range Defaults {
+				for _, perm := range perms {
+					if perm != p.Name {
+						continue
+					}
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+						Create(&repo.RolePermission{Role: role, Permission: perm}).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+type cacheEntry struct {
+	perms   map[string]bool
+	expires time.Time
+}
+
+// 授权缓存：本进程内修改立即生效，其他实例最多延迟 cacheTTL
+var (
+	cacheMu  sync.RWMutex
+	cache    = map[repo.Role]cacheEntry{}
+	cacheTTL = 30 * time.Second
+)
+
+func load(db *gorm.DB, role repo.Role) (map[string]bool, error) {
+	cacheMu.RLock()
+	e, ok := cache[role]
+	cacheMu.RUnlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.perms, nil
+	}
+
+	var names []string
+	if err := db.Model(&repo.RolePermission{}).Where("role = ?", role).Pluck("permission", &names).Error; err != nil {
+		return nil, err
+	}
+	perms := make(map[string]bool, len(names))
+	for _, n := range names {
+		perms[n] = true
+	}
+
+	cacheMu.Lock()
+	cache[role] = cacheEntry{perms: perms, expires: time.Now().Add(cacheTTL)}
+	cacheMu.Unlock()
+	return perms, nil
+}
+
+// Has 判断角色是否拥有权限；超级管理员始终拥有全部权限
+func Has(db *gorm.DB, role repo.Role, perm string) (bool, error) {
+	if role == repo.RoleSuperAdmin {
+		return true, nil
+	}
+	perms, err := load(db, role)
+	if err != nil {
+		return false, err
+	}
+	return perms[perm], nil
+}
+
+// IsStaff 判断角色能否进入管理后台：超级管理员，或至少被授予一项权限
+func IsStaff(db *gorm.DB, role repo.Role) (bool, error) {
+	if role == repo.RoleSuperAdmin {
+		return true, nil
+	}
+	perms, err := load(db, role)
+	if err != nil {
+		return false, err
+	}
+	return len(perms) > 0, nil
+}
+
+// Permissions 返回角色拥有的权限列表
+func Permissions(db *gorm.DB, role repo.Role) ([]string, error) {
+	if role == repo.RoleSuperAdmin {
+		out := make([]string, 0, len(All))
+		for _, p := range All {
+			out = append(out, p.Name)
+		}
+		return out, nil
+	}
+	perms, err := load(db, role)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(perms))
+	for p := range perms {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// SetPermissions 替换角色的全部授权
+func SetPermissions(db *gorm.DB, role repo.Role, perms []string) error {
+	for _, p := range perms {
+		if !IsKnown(p) {
+			return ErrUnknownPermission
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", role).Delete(&repo.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for _, p := range perms {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&repo.RolePermission{Role: role, Permission: p}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	Invalidate(role)
+	return err
+}
+
+// Invalidate 清除角色的授权缓存
+func Invalidate(role repo.Role) {
+	cacheMu.Lock()
+	delete(cache, role)
+	cacheMu.Unlock()
+}
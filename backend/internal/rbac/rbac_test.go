@@ -0,0 +1,118 @@
+package rbac
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"maimang/backend/internal/repo"
+	"maimang/backend/internal/testdb"
+)
+
+func TestDefaultsAreKnown(t *testing.T) {
+	for role, perms := range Defaults {
+		if !IsConfigurable(role) {
+			t.Errorf("default grants for non-configurable role %s", role)
+		}
+		for _, p := range perms {
+			if !IsKnown(p) {
+				t.Errorf("role %s: unknown default permission %q", role, p)
+			}
+		}
+	}
+	if IsConfigurable(repo.RoleSuperAdmin) {
+		t.Error("super_admin must not be configurable")
+	}
+}
+
+// 缓存命中时不访问数据库，db 传 nil 即可
+func TestHasCached(t *testing.T) {
+	const role repo.Role = "test_cached"
+	cacheMu.Lock()
+	cache[role] = cacheEntry{perms: map[string]bool{WorksReview: true}, expires: time.Now().Add(time.Minute)}
+	cacheMu.Unlock()
+	defer Invalidate(role)
+
+	tests := []struct {
+		name string
+		role repo.Role
+		perm string
+		want bool
+	}{
+		{"已授予的权限", role, WorksReview, true},
+		{"未授予的权限", role, UsersDelete, false},
+		{"超级管理员拥有全部权限", repo.RoleSuperAdmin, SettingsWrite, true},
+		{"超级管理员拥有未声明的权限", repo.RoleSuperAdmin, "anything", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Has(nil, tt.role, tt.perm)
+			if err != nil || got != tt.want {
+				t.Errorf("Has(%s, %s) = %v, %v, want %v", tt.role, tt.perm, got, err, tt.want)
+			}
+		})
+	}
+	if staff, err := IsStaff(nil, role); err != nil || !staff {
+		t.Errorf("IsStaff(%s) = %v, %v, want true", role, staff, err)
+	}
+}
+
+func TestSeedAndSetPermissions(t *testing.T) {
+	db := testdb.Open(t, &repo.Permission{}, &repo.RolePermission{})
+	for _, r := range append(Roles, repo.RoleSuperAdmin) {
+		Invalidate(r)
+	}
+	if err := Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role  repo.Role
+		perm  string
+		want  bool
+		staff bool
+	}{
+		{repo.RoleAdmin, SettingsWrite, true, true},
+		{repo.RoleEditor, SettingsWrite, false, true},
+		{repo.RoleReviewer, CommentsReview, true, true},
+		{repo.RoleMember, DashboardView, false, false},
+	}
+	for _, tt := range tests {
+		got, err := Has(db, tt.role, tt.perm)
+		if err != nil || got != tt.want {
+			t.Errorf("Has(%s, %s) = %v, %v, want %v", tt.role, tt.perm, got, err, tt.want)
+		}
+		if staff, err := IsStaff(db, tt.role); err != nil || staff != tt.staff {
+			t.Errorf("IsStaff(%s) = %v, %v, want %v", tt.role, staff, err, tt.staff)
+		}
+	}
+
+	// 修改授权后立即生效，不等缓存过期
+	if err := SetPermissions(db, repo.RoleMember, []string{WorksReview}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := Has(db, repo.RoleMember, WorksReview); !ok {
+		t.Error("granted permission not visible after SetPermissions")
+	}
+	if staff, _ := IsStaff(db, repo.RoleMember); !staff {
+		t.Error("member with a permission should be staff")
+	}
+	if err := SetPermissions(db, repo.RoleMember, []string{"no.such"}); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("SetPermissions unknown error = %v", err)
+	}
+
+	// 再次 Seed 不覆盖管理员的调整
+	if err := SetPermissions(db, repo.RoleEditor, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(db); err != nil {
+		t.Fatal(err)
+	}
+	if perms, _ := Permissions(db, repo.RoleEditor); len(perms) != 0 {
+		t.Errorf("Seed restored editor permissions: %v", perms)
+	}
+	if perms, _ := Permissions(db, repo.RoleMember); !reflect.DeepEqual(perms, []string{WorksReview}) {
+		t.Errorf("member permissions = %v", perms)
+	}
+}
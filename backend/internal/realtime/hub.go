@@ -0,0 +1,173 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// Channel Postgres NOTIFY 使用的频道；各实例都 LISTEN 该频道，再分发给本机的连接
+const Channel = "maimang_events"
+
+// NOTIFY 负载上限为 8000 字节，超出时只推送引用，由客户端自行拉取
+const maxPayload = 7900
+
+// 事件类型
+const (
+	EventMessage         = "message"          // 新私信
+	EventMessageRead     = "message_read"     // 对方已读私信
+	EventMessageRecalled = "message_recalled" // 私信被撤回
+	EventNotification    = "notification"     // 新通知
+)
+
+// Event 推送给某个用户的事件
+type Event struct {
+	UserID uint            `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Publish 经 Postgres NOTIFY 广播事件，所有实例上该用户的连接都会收到。
+// ref 为负载过大时代替 data 推送的精简内容；失败只记录日志
+func Publish(db *gorm.DB, uid uint, typ string, data, ref interface{}) {
+	payload, err := encode(uid, typ, data)
+	if err == nil && len(payload) > maxPayload && ref != nil {
+		payload, err = encode(uid, typ, ref)
+	}
+	if err != nil {
+		log.Printf("Encode realtime event error: %v", err)
+		return
+	}
+	if len(payload) > maxPayload {
+		log.Printf("Realtime event %s for user %d too large, dropped", typ, uid)
+		return
+	}
+	if err := db.Exec("SELECT pg_notify(?, ?)", Channel, string(payload)).Error; err != nil {
+		log.Printf("Publish realtime event error: %v", err)
+	}
+}
+
+func encode(uid uint, typ string, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Event{UserID: uid, Type: typ, Data: raw})
+}
+
+// Subscriber 一个实时连接；C 在连接被移除或 Hub 关闭后关闭
+type Subscriber struct {
+	UserID uint
+	C      chan Event
+}
+
+// Hub 把收到的事件分发给本实例上对应用户的连接
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[uint]map[*Subscriber]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[uint]map[*Subscriber]struct{})}
+}
+
+// Subscribe 注册用户的一个连接；Hub 已关闭时返回 nil
+func (h *Hub) Subscribe(uid uint) *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	s := &Subscriber{UserID: uid, C: make(chan Event, 32)}
+	if h.subs[uid] == nil {
+		h.subs[uid] = make(map[*Subscriber]struct{})
+	}
+	h.subs[uid][s] = struct{}{}
+	return s
+}
+
+// Unsubscribe 移除连接并关闭其通道，可重复调用
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s.UserID][s]; !ok {
+		return
+	}
+	delete(h.subs[s.UserID], s)
+	if len(h.subs[s.UserID]) == 0 {
+		delete(h.subs, s.UserID)
+	}
+	close(s.C)
+}
+
+// Close 关闭全部连接，用于服务停止时让长连接尽快结束
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for uid, set := range h.subs {
+		for s := range set {
+			close(s.C)
+		}
+		delete(h.subs, uid)
+	}
+}
+
+// dispatch 投递事件；连接处理不过来时丢弃，客户端可通过接口补齐
+func (h *Hub) dispatch(e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs[e.UserID] {
+		select {
+		case s.C <- e:
+		default:
+		}
+	}
+}
+
+// Listen 持有一个独立的数据库连接 LISTEN 事件频道，断线后自动重连，ctx 取消时退出
+func (h *Hub) Listen(ctx context.Context, dsn string) {
+	for {
+		err := h.listen(ctx, dsn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Realtime listener error: %v, reconnecting", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			log.Printf("Decode realtime event error: %v", err)
+			continue
+		}
+		h.dispatch(e)
+	}
+}
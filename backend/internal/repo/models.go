@@ -41,12 +41,219 @@ type User struct {
 	Status      string     `gorm:"type:varchar(20);not null;default:'active';index"` // active, inactive, banned
 	LastLoginAt *time.Time `gorm:"index"`
 
+	// 令牌版本：递增后此前签发的访问令牌全部失效
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+
+	// 邮箱验证时间，未验证为空
+	EmailVerifiedAt *time.Time `json:"-"`
+
+	// 注册审核：审核模式下新账号为 inactive 且 PendingApproval 为 true，审核通过后激活
+	PendingApproval bool   `gorm:"not null;default:false;index" json:"-"`
+	ApplicationNote string `gorm:"size:1000" json:"-"` // 申请加入时填写的说明
+	InviteCodeID    *uint  `gorm:"index" json:"-"`     // 注册时使用的邀请码
+
+	// 私信权限：谁可以给我发私信
+	DMPolicy string `gorm:"type:varchar(20);not null;default:'everyone'" json:"-"`
+
+	// 两步验证（TOTP）
+	TOTPSecret   string `gorm:"size:64" json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"-"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"` // 最近一次使用的时间步长，防止验证码重放
+
 	// 关联关系
 	Works                []Work                `gorm:"foreignKey:AuthorID"`
 	Comments             []Comment             `gorm:"foreignKey:AuthorID"`
 	ActivityParticipants []ActivityParticipant `gorm:"foreignKey:UserID"`
 }
 
+// 私信权限
+const (
+	DMEveryone  = "everyone"  // 任何人
+	DMFollowing = "following" // 仅我关注的人
+	DMNobody    = "nobody"    // 不接收私信
+)
+
+// 关注：UserID 关注了 FollowingID
+type Follow struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID      uint `gorm:"not null;uniqueIndex:idx_follow_pair"`
+	FollowingID uint `gorm:"not null;uniqueIndex:idx_follow_pair;index"`
+	Following   User `gorm:"foreignKey:FollowingID"`
+}
+
+// 拉黑：UserID 拉黑了 BlockedID，双方不能互发私信，BlockedID 的私信和评论对 UserID 隐藏
+type UserBlock struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID    uint `gorm:"not null;uniqueIndex:idx_user_block_pair"`
+	BlockedID uint `gorm:"not null;uniqueIndex:idx_user_block_pair;index"`
+	Blocked   User `gorm:"foreignKey:BlockedID"`
+}
+
+// 刷新令牌：只保存哈希；同一次登录轮换出的令牌属于同一个家族（FamilyID）
+type RefreshToken struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID    uint       `gorm:"not null;index"`
+	FamilyID  string     `gorm:"size:36;not null;index"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	UsedAt    *time.Time // 已轮换为新令牌的时间
+	RevokedAt *time.Time `gorm:"index"`
+}
+
+// 登录会话：每次登录创建一个，ID 与该次登录的刷新令牌 FamilyID 相同
+type Session struct {
+	ID        string `gorm:"primaryKey;size:36"`
+	CreatedAt time.Time
+
+	UserID     uint       `gorm:"not null;index"`
+	Device     string     `gorm:"size:100"` // 由 User-Agent 解析出的设备描述
+	UserAgent  string     `gorm:"size:500"`
+	IP         string     `gorm:"size:64"`
+	LastSeenAt time.Time  `gorm:"not null"`
+	ExpiresAt  time.Time  `gorm:"not null;index"`
+	RevokedAt  *time.Time `gorm:"index"`
+
+	// 是否为发起请求的当前会话（非数据库字段）
+	Current bool `gorm:"-" json:"current"`
+}
+
+// 一次性令牌用途
+const (
+	TokenPasswordReset = "password_reset"
+	TokenEmailVerify   = "email_verify"
+	TokenOIDCLogin     = "oidc_login" // 第三方登录回调后换取令牌的短时凭据
+)
+
+// 一次性令牌：找回密码、邮箱验证等，只保存哈希
+type UserToken struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"size:30;not null;index"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+}
+
+// 两步验证恢复码：每个只能使用一次，只保存哈希
+type RecoveryCode struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"size:64;not null;index" json:"-"`
+	UsedAt   *time.Time
+}
+
+// 登录失败计数：按 "email:<邮箱>" 和 "ip:<地址>" 两类键分别记录
+type LoginThrottle struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Key           string     `gorm:"size:255;uniqueIndex;not null"`
+	Failures      int        `gorm:"not null;default:0"`
+	LastFailureAt time.Time  `gorm:"not null"`
+	LockedUntil   *time.Time `gorm:"index"`
+}
+
+// 账号锁定审计记录，用于分析撞库攻击
+type LockoutEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	Key         string    `gorm:"size:255;not null;index"`
+	Email       string    `gorm:"size:200;index"`
+	IP          string    `gorm:"size:64;index"`
+	Failures    int       `gorm:"not null"`
+	LockedUntil time.Time `gorm:"not null"`
+	ClearedBy   *uint
+	ClearedAt   *time.Time
+}
+
+// 个人访问令牌：用于脚本调用 API，只保存哈希；Scopes 为逗号分隔的权限范围
+type APIToken struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID     uint       `gorm:"not null;index"`
+	Name       string     `gorm:"size:100;not null"`
+	Prefix     string     `gorm:"size:20;not null"` // 令牌前几位，便于用户辨认
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"size:500;not null"`
+	ExpiresAt  *time.Time `gorm:"index"`
+	LastUsedAt *time.Time
+	LastUsedIP string     `gorm:"size:64"`
+	RevokedAt  *time.Time `gorm:"index"`
+	RevokedBy  *uint
+}
+
+// 邀请码：MaxUses 为 0 表示不限次数，ExpiresAt 为空表示永不过期
+type InviteCode struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Code      string `gorm:"size:32;uniqueIndex;not null"`
+	Note      string `gorm:"size:200"`
+	MaxUses   int    `gorm:"not null;default:1"`
+	Uses      int    `gorm:"not null;default:0"`
+	ExpiresAt *time.Time
+	CreatedBy uint `gorm:"not null"`
+	RevokedAt *time.Time
+}
+
+// 第三方登录发起时保存的状态，回调时一次性取出；只保存 state 的哈希
+type OAuthState struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	StateHash    string    `gorm:"size:64;uniqueIndex;not null"`
+	Provider     string    `gorm:"size:50;not null"`
+	Nonce        string    `gorm:"size:100;not null"`
+	CodeVerifier string    `gorm:"size:100;not null"`
+	RedirectTo   string    `gorm:"size:500"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
+
+// 第三方身份与本站用户的绑定，Subject 为身份提供方的用户标识
+type UserIdentity struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID      uint   `gorm:"not null;index"`
+	Provider    string `gorm:"size:50;not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject     string `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject"`
+	Email       string `gorm:"size:200"`
+	LastLoginAt *time.Time
+}
+
+// 权限点：代码中声明的权限首次出现时写入并按默认授权，之后以数据库为准
+type Permission struct {
+	Name        string `gorm:"primaryKey;size:50"`
+	Description string `gorm:"size:200"`
+	CreatedAt   time.Time
+}
+
+// 角色授权：超级管理员隐式拥有全部权限，不在此表中
+type RolePermission struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	Role       Role   `gorm:"type:varchar(20);not null;uniqueIndex:idx_role_permission"`
+	Permission string `gorm:"size:50;not null;uniqueIndex:idx_role_permission"`
+}
+
 type ArticleStatus string
 
 const (
@@ -141,6 +348,20 @@ type Work struct {
 
 	// 关联关系
 	Comments []Comment `gorm:"foreignKey:WorkID"`
+	Mentions []Mention `gorm:"polymorphic:Target;polymorphicValue:work"`
+
+	// 当前登录用户是否已点赞（非数据库字段）
+	LikedByMe bool `gorm:"-" json:"liked_by_me"`
+}
+
+// 作品点赞记录：每个用户对每个作品最多一条
+type WorkLike struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	WorkID uint `gorm:"not null;uniqueIndex:idx_work_likes_work_user"`
+	Work   Work `gorm:"foreignKey:WorkID"`
+	UserID uint `gorm:"not null;uniqueIndex:idx_work_likes_work_user;index"`
 }
 
 // 评论管理
@@ -166,14 +387,84 @@ type Comment struct {
 	WorkID   uint          `gorm:"not null;index"`
 	Work     Work          `gorm:"foreignKey:WorkID"`
 
+	// 回复：只有两层，回复的回复也挂在顶层评论下，ReplyToUserID 记录被回复的人
+	ParentID      *uint `gorm:"index"`
+	ReplyToUserID *uint
+	ReplyToUser   *User `gorm:"foreignKey:ReplyToUserID"`
+
 	// 统计数据
 	Likes   int `gorm:"default:0;index"`
-	Replies int `gorm:"default:0"`
+	Replies int `gorm:"default:0"` // 已通过审核的回复数
 
 	// 审核信息
 	ReviewedAt *time.Time
 	ReviewedBy *uint
 	Reviewer   *User `gorm:"foreignKey:ReviewedBy"`
+
+	ReviewNote string `gorm:"size:1000"` // 最近一次人工审核的备注
+
+	// 自动审核：AutoModerated 表示当前状态由系统判定，ModerationReason 为判定依据
+	AutoModerated    bool   `gorm:"not null;default:false;index"`
+	ModerationReason string `gorm:"size:500" json:"-"` // 可能包含词表内容，只经后台接口返回
+
+	// @提及
+	Mentions []Mention `gorm:"polymorphic:Target;polymorphicValue:comment"`
+
+	// 当前登录用户是否已点赞（非数据库字段）
+	LikedByMe bool `gorm:"-" json:"liked_by_me"`
+}
+
+// 评论修改记录：每次编辑前保存旧内容和当时的状态
+type CommentRevision struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	CommentID uint          `gorm:"not null;index"`
+	EditorID  uint          `gorm:"not null"`
+	Editor    User          `gorm:"foreignKey:EditorID"`
+	Content   string        `gorm:"type:text;not null"`
+	Status    CommentStatus `gorm:"type:varchar(20);not null"`
+}
+
+// 评论审核记录：每次人工审核和自动审核的判定
+type CommentReviewLog struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	CommentID  uint          `gorm:"not null;index"`
+	ReviewerID *uint         // 为空表示系统自动审核
+	Reviewer   *User         `gorm:"foreignKey:ReviewerID"`
+	Action     string        `gorm:"size:20;not null"` // approve / reject / hide / unhide / pend / auto
+	FromStatus CommentStatus `gorm:"type:varchar(20)"` // 新建评论时为空
+	ToStatus   CommentStatus `gorm:"type:varchar(20);not null"`
+	Note       string        `gorm:"size:1000"` // 审核员备注或自动审核的判定原因
+}
+
+// @提及记录：内容保存时重新解析并整体替换
+type Mention struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	TargetType string `gorm:"size:20;not null;index:idx_mentions_target"` // comment / work
+	TargetID   uint   `gorm:"not null;index:idx_mentions_target"`
+	AuthorID   uint   `gorm:"not null" json:"-"`
+	UserID     uint   `gorm:"not null;index"` // 被提及的用户
+
+	// 在内容中的位置，按 UTF-16 码元计，与前端 JS 字符串下标一致
+	Start int `gorm:"not null"`
+	End   int `gorm:"not null"`
+
+	NotifiedAt *time.Time `json:"-"` // 内容公开后才通知，只通知一次
+}
+
+// 评论点赞记录：每个用户对每条评论最多一条
+type CommentLike struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	CommentID uint    `gorm:"not null;uniqueIndex:idx_comment_likes_comment_user"`
+	Comment   Comment `gorm:"foreignKey:CommentID"`
+	UserID    uint    `gorm:"not null;uniqueIndex:idx_comment_likes_comment_user;index"`
 }
 
 // 活动管理
@@ -317,4 +608,115 @@ type Message struct {
 
 	Content string     `gorm:"type:text;not null"`
 	ReadAt  *time.Time `gorm:"index"`
+
+	// 撤回时间：撤回后内容和附件不再返回给双方，原文保留供举报处理
+	RecalledAt *time.Time `gorm:"index"`
+	// 仅为自己删除：只对删除的一方隐藏
+	DeletedBySender    bool `gorm:"not null;default:false" json:"-"`
+	DeletedByRecipient bool `gorm:"not null;default:false" json:"-"`
+
+	Attachments []MessageAttachment `gorm:"foreignKey:MessageID"`
+}
+
+// 私信附件：先上传，发送消息时再关联到消息；文件不公开，只能由会话双方下载
+type MessageAttachment struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	MessageID   *uint  `gorm:"index"` // 尚未发送时为空
+	UploaderID  uint   `gorm:"not null;index"`
+	Name        string `gorm:"size:255;not null"`
+	ContentType string `gorm:"size:100;not null"`
+	Size        int64  `gorm:"not null"`
+	Path        string `gorm:"size:500;not null" json:"-"`
+}
+
+// 举报处理状态
+type ModerationStatus string
+
+const (
+	ModerationOpen      ModerationStatus = "open"
+	ModerationResolved  ModerationStatus = "resolved"
+	ModerationDismissed ModerationStatus = "dismissed"
+)
+
+// 举报案件：举报时保存相关内容的快照，之后撤回或删除不影响处理
+type ModerationCase struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Kind           string `gorm:"size:30;not null;index"` // conversation
+	ReporterID     uint   `gorm:"not null;index"`
+	Reporter       User   `gorm:"foreignKey:ReporterID"`
+	ReportedUserID uint   `gorm:"not null;index"`
+	ReportedUser   User   `gorm:"foreignKey:ReportedUserID"`
+	Reason         string `gorm:"size:1000"`
+	Snapshot       []byte `gorm:"type:jsonb;not null" json:"-"`
+
+	Status         ModerationStatus `gorm:"type:varchar(20);not null;default:'open';index"`
+	ResolvedAt     *time.Time
+	ResolvedBy     *uint
+	Resolver       *User  `gorm:"foreignKey:ResolvedBy"`
+	ResolutionNote string `gorm:"size:1000"`
+}
+
+// 站内通知
+type NotificationType string
+
+const (
+	NotificationWorkApproved    NotificationType = "work_approved"
+	NotificationWorkRejected    NotificationType = "work_rejected"
+	NotificationWorkComment     NotificationType = "work_comment"     // 我的作品有新评论
+	NotificationCommentApproved NotificationType = "comment_approved" // 我的评论通过审核
+	NotificationCommentReply    NotificationType = "comment_reply"    // 我的评论有新回复
+	NotificationMention         NotificationType = "mention"          // 有人 @ 了我
+	NotificationMessage         NotificationType = "message"          // 收到私信
+	NotificationActivityStatus  NotificationType = "activity_status"  // 报名的活动状态变更
+)
+
+type Notification struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID  uint             `gorm:"not null;index:idx_notification_user_read,priority:1"`
+	Type    NotificationType `gorm:"type:varchar(30);not null;index"`
+	Title   string           `gorm:"size:200;not null"`
+	Content string           `gorm:"size:1000"`
+	ActorID *uint            // 触发通知的用户，系统通知为空
+	Actor   *User            `gorm:"foreignKey:ActorID"`
+	// 关联对象，如 work / comment / activity / user
+	TargetType string     `gorm:"size:30"`
+	TargetID   uint       `gorm:"index"`
+	ReadAt     *time.Time `gorm:"index:idx_notification_user_read,priority:2"`
+
+	// 用户关闭了该类型的站内通知、只接收邮件时为 true，不在通知列表中展示
+	Silent bool `gorm:"not null;default:false;index"`
+	// 等待邮件发送（即时邮件或摘要）
+	EmailPending bool `gorm:"not null;default:false;index"`
+}
+
+// 邮件通知频率
+const (
+	EmailInstant = "instant" // 每条通知单独发送
+	EmailDaily   = "daily"   // 每日摘要
+	EmailWeekly  = "weekly"  // 每周摘要
+)
+
+// 用户的通知设置；没有记录时使用默认设置
+type NotificationSetting struct {
+	UserID    uint `gorm:"primaryKey"`
+	UpdatedAt time.Time
+
+	EmailFrequency string     `gorm:"size:10;not null"`
+	LastDigestAt   *time.Time // 上次发送通知邮件的时间
+}
+
+// 用户对某类通知的接收方式；没有记录的类型使用默认值
+type NotificationPreference struct {
+	UserID uint             `gorm:"primaryKey"`
+	Type   NotificationType `gorm:"primaryKey;type:varchar(30)"`
+	InApp  bool             `gorm:"not null"`
+	Email  bool             `gorm:"not null"`
 }
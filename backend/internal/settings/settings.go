@@ -0,0 +1,152 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"maimang/backend/internal/repo"
+)
+
+// 系统设置键
+const (
+	RequireEmailVerification = "require_email_verification" // bool：投稿前必须验证邮箱
+	RequireAdmin2FA          = "require_admin_2fa"          // bool：管理后台角色必须启用两步验证
+	RegistrationMode         = "registration_mode"          // string：open / invite / approval
+
+	// 评论自动审核
+	CommentBlockWords         = "comment_block_words"          // 列表：命中即自动拒绝
+	CommentReviewWords        = "comment_review_words"         // 列表：命中转人工审核
+	CommentMaxLinks           = "comment_max_links"            // int：链接数超过时转人工审核
+	CommentAutoApprove        = "comment_auto_approve"         // bool：可信用户的评论自动通过
+	CommentTrustedMinApproved = "comment_trusted_min_approved" // int：成为可信用户所需的已通过评论数
+	CommentEditRequiresReview = "comment_edit_requires_review" // bool：已公开的评论编辑后重新进入待审核
+)
+
+// 不对外公开的设置，公开的 /settings 接口不返回
+var private = map[string]bool{
+	CommentBlockWords:  true,
+	CommentReviewWords: true,
+}
+
+// IsPrivate 判断设置是否只对管理员可见
+func IsPrivate(key string) bool {
+	return private[key]
+}
+
+// 注册模式
+const (
+	RegistrationOpen     = "open"     // 任何人可注册
+	RegistrationInvite   = "invite"   // 需要邀请码
+	RegistrationApproval = "approval" // 注册后需管理员审核
+)
+
+// Registration 返回当前注册模式，未设置或无法识别时按开放注册处理
+func Registration(db *gorm.DB) string {
+	switch mode := String(db, RegistrationMode, RegistrationOpen); mode {
+	case RegistrationInvite, RegistrationApproval:
+		return mode
+	}
+	return RegistrationOpen
+}
+
+type cacheEntry struct {
+	value   string
+	ok      bool
+	expires time.Time
+}
+
+// 设置缓存：本进程内修改后调用 Invalidate 立即生效，其他实例最多延迟 cacheTTL
+var (
+	cacheMu  sync.RWMutex
+	cache    = map[string]cacheEntry{}
+	cacheTTL = 30 * time.Second
+)
+
+// Get 读取设置原始值，不存在时 ok 为 false
+func Get(db *gorm.DB, key string) (string, bool) {
+	cacheMu.RLock()
+	e, hit := cache[key]
+	cacheMu.RUnlock()
+	if hit && time.Now().Before(e.expires) {
+		return e.value, e.ok
+	}
+
+	var setting repo.SystemSetting
+	err := db.Where("key = ?", key).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		// 查询失败不缓存，下次重试
+		return "", false
+	}
+	e = cacheEntry{value: setting.Value, ok: err == nil, expires: time.Now().Add(cacheTTL)}
+	cacheMu.Lock()
+	cache[key] = e
+	cacheMu.Unlock()
+	return e.value, e.ok
+}
+
+// Invalidate 清除设置缓存，修改设置后调用
+func Invalidate() {
+	cacheMu.Lock()
+	cache = map[string]cacheEntry{}
+	cacheMu.Unlock()
+}
+
+// Bool 读取布尔设置，不存在或无法解析时返回 def
+func Bool(db *gorm.DB, key string, def bool) bool {
+	v, ok := Get(db, key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// Int 读取整数设置，不存在或无法解析时返回 def
+func Int(db *gorm.DB, key string, def int) int {
+	v, ok := Get(db, key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// Strings 读取列表设置：JSON 字符串数组，或按换行、逗号分隔的文本；空项被忽略
+func Strings(db *gorm.DB, key string) []string {
+	v, ok := Get(db, key)
+	if !ok {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(v), &list); err != nil {
+		list = strings.FieldsFunc(v, func(r rune) bool { return r == '\n' || r == ',' || r == '，' })
+	}
+	out := list[:0]
+	for _, item := range list {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// String 读取字符串设置，不存在时返回 def
+func String(db *gorm.DB, key string, def string) string {
+	v, ok := Get(db, key)
+	if !ok || v == "" {
+		return def
+	}
+	return v
+}
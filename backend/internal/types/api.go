@@ -22,6 +22,13 @@ type PaginationMeta struct {
 	TotalPages int   `json:"total_pages"`
 }
 
+// 游标分页响应结构；next_cursor 为空表示没有更多数据
+type CursorResponse struct {
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
 // 认证相关请求
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required,min=2,max=100"`
@@ -45,6 +52,60 @@ type UpdateUserRequest struct {
 	Bio       string `json:"bio,omitempty" validate:"omitempty,max=1000"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 表示使用默认有效期
+}
+
+type UpdateAPITokenRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type CreateInviteCodesRequest struct {
+	Count         int    `json:"count"`    // 生成数量，默认 1
+	MaxUses       *int   `json:"max_uses"` // 每个邀请码可用次数，默认 1，0 表示不限
+	ExpiresInDays int    `json:"expires_in_days"`
+	Note          string `json:"note"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateNotificationSettingsRequest struct {
+	EmailFrequency string `json:"email_frequency" validate:"omitempty,oneof=instant daily weekly"`
+	Types          []struct {
+		Type  string `json:"type"`
+		InApp bool   `json:"in_app"`
+		Email bool   `json:"email"`
+	} `json:"types"`
+}
+
+type UserIDRequest struct {
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+type UpdatePrivacyRequest struct {
+	DMPolicy string `json:"dm_policy" validate:"required,oneof=everyone following nobody"`
+}
+
+// 举报会话：message_ids 为空时快照最近的消息
+type ReportConversationRequest struct {
+	Reason     string `json:"reason" validate:"required,max=1000"`
+	MessageIDs []uint `json:"message_ids" validate:"omitempty,max=50"`
+}
+
+type UpdateModerationCaseRequest struct {
+	Status string `json:"status" validate:"required,oneof=open resolved dismissed"`
+	Note   string `json:"note" validate:"omitempty,max=1000"`
+}
+
 type UpdateUserStatusRequest struct {
 	Status string `json:"status" validate:"required,oneof=active inactive banned"`
 }